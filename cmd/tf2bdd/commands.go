@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leighmacdonald/steamid/v4/steamid"
+	"github.com/leighmacdonald/tf2bdd/tf2bdd"
+)
+
+// openDatabase opens the configured database, applying any pending migrations.
+func openDatabase(config tf2bdd.Config) (*sql.DB, error) {
+	database, errDatabase := tf2bdd.OpenDB(config.DatabasePath)
+	if errDatabase != nil {
+		return nil, errDatabase
+	}
+
+	if errSetupDB := tf2bdd.SetupDB(database); errSetupDB != nil {
+		return nil, errSetupDB
+	}
+
+	return database, nil
+}
+
+func closeDatabase(database *sql.DB) {
+	if errClose := database.Close(); errClose != nil {
+		slog.Error("Failed to close database", slog.String("error", errClose.Error()))
+	}
+}
+
+// parseSteamID parses a steam id in any of the non-vanity formats. Vanity names are not supported as
+// resolving them requires the steam api.
+func parseSteamID(value string) (steamid.SteamID, error) {
+	sid := steamid.New(value)
+	if !sid.Valid() {
+		return sid, fmt.Errorf("invalid steam id: %s", value)
+	}
+
+	return sid, nil
+}
+
+func validateConfig(config tf2bdd.Config) error {
+	if errValidate := tf2bdd.ValidateConfig(config); errValidate != nil {
+		return fmt.Errorf("config file validation error: %w", errValidate)
+	}
+
+	fmt.Println("Config is valid")
+
+	return nil
+}
+
+func migrateDB(config tf2bdd.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("migrate requires one of: up, down, version")
+	}
+
+	action := args[0]
+
+	flags := flag.NewFlagSet("migrate "+action, flag.ContinueOnError)
+	steps := flags.Int("steps", 1, "Amount of migrations to revert")
+
+	if errParse := flags.Parse(args[1:]); errParse != nil {
+		return errParse
+	}
+
+	database, errDatabase := tf2bdd.OpenDB(config.DatabasePath)
+	if errDatabase != nil {
+		return errDatabase
+	}
+
+	defer closeDatabase(database)
+
+	switch action {
+	case "up":
+		if errSetup := tf2bdd.SetupDB(database); errSetup != nil {
+			return errSetup
+		}
+	case "down":
+		if *steps <= 0 {
+			return errors.New("steps must be greater than 0")
+		}
+
+		if errDown := tf2bdd.MigrateDown(database, *steps); errDown != nil {
+			return errDown
+		}
+	case "version":
+	default:
+		return fmt.Errorf("unknown migrate action: %s", action)
+	}
+
+	version, dirty, errVersion := tf2bdd.MigrationVersion(database)
+	if errVersion != nil {
+		return errVersion
+	}
+
+	fmt.Printf("Schema version: %d (dirty: %t)\n", version, dirty)
+
+	return nil
+}
+
+func addPlayer(config tf2bdd.Config, args []string) error {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	attrs := flags.String("attrs", "", "Comma separated attributes, defaults to the first known attribute")
+	name := flags.String("name", "", "Last known name of the player")
+	proof := flags.String("proof", "", "Proof entry in the form of: value | note")
+	author := flags.Int64("author", 0, "Discord user id recorded as the author")
+
+	if errParse := flags.Parse(args); errParse != nil {
+		return errParse
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("add requires a steam id")
+	}
+
+	sid, errSid := parseSteamID(flags.Arg(0))
+	if errSid != nil {
+		return errSid
+	}
+
+	player := tf2bdd.Player{
+		SteamID:    sid,
+		Attributes: strings.Split(*attrs, ","),
+		LastSeen:   tf2bdd.LastSeen{PlayerName: *name, Time: time.Now().Unix()},
+		Proof:      tf2bdd.Proof{},
+	}
+
+	if *proof != "" {
+		value, note, _ := strings.Cut(*proof, "|")
+		player.Proof = append(player.Proof, tf2bdd.ProofEntry{Value: strings.TrimSpace(value), Note: strings.TrimSpace(note)})
+	}
+
+	database, errDatabase := openDatabase(config)
+	if errDatabase != nil {
+		return errDatabase
+	}
+
+	defer closeDatabase(database)
+
+	added, errAdd := tf2bdd.CreateEntry(context.Background(), database, config, player, config.KnownAttributes[0], *author)
+	if errAdd != nil {
+		if errors.Is(errAdd, tf2bdd.ErrDuplicate) {
+			return fmt.Errorf("duplicate steam id: %s", sid.String())
+		}
+
+		return errAdd
+	}
+
+	if !added.Confirmed {
+		fmt.Printf("Added new entry, awaiting confirmation: %s\n", sid.String())
+
+		return nil
+	}
+
+	fmt.Printf("Added new entry successfully: %s\n", sid.String())
+
+	return nil
+}
+
+func deletePlayer(config tf2bdd.Config, args []string) error {
+	flags := flag.NewFlagSet("del", flag.ContinueOnError)
+	author := flags.Int64("author", 0, "Discord user id recorded as the author")
+
+	if errParse := flags.Parse(args); errParse != nil {
+		return errParse
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("del requires a steam id")
+	}
+
+	sid, errSid := parseSteamID(flags.Arg(0))
+	if errSid != nil {
+		return errSid
+	}
+
+	database, errDatabase := openDatabase(config)
+	if errDatabase != nil {
+		return errDatabase
+	}
+
+	defer closeDatabase(database)
+
+	if errDrop := tf2bdd.DropPlayer(context.Background(), database, sid, *author); errDrop != nil {
+		if errors.Is(errDrop, tf2bdd.ErrNotFound) {
+			return fmt.Errorf("steam id does not exist in database: %s", sid.String())
+		}
+
+		return errDrop
+	}
+
+	fmt.Printf("Deleted entry successfully: %s\n", sid.String())
+
+	return nil
+}
+
+func checkPlayer(config tf2bdd.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New("check requires a steam id")
+	}
+
+	sid, errSid := parseSteamID(args[0])
+	if errSid != nil {
+		return errSid
+	}
+
+	database, errDatabase := openDatabase(config)
+	if errDatabase != nil {
+		return errDatabase
+	}
+
+	defer closeDatabase(database)
+
+	player, errPlayer := tf2bdd.GetPlayer(context.Background(), database, sid)
+	if errPlayer != nil {
+		if errors.Is(errPlayer, tf2bdd.ErrNotFound) {
+			return fmt.Errorf("steam id does not exist in database: %s", sid.String())
+		}
+
+		return errPlayer
+	}
+
+	fmt.Printf("SteamID:    %s\n", player.SteamID.String())
+	fmt.Printf("Name:       %s\n", player.LastSeen.PlayerName)
+	fmt.Printf("Attributes: %s\n", strings.Join(player.Attributes, ", "))
+	fmt.Printf("Confirmed:  %t\n", player.Confirmed)
+	fmt.Printf("Author:     %d\n", player.Author)
+	fmt.Printf("Added on:   %s\n", player.CreatedOn.String())
+
+	for idx, proof := range player.Proof {
+		if proof.Note != "" {
+			fmt.Printf("Proof #%d:   %s - %s\n", idx, proof.Value, proof.Note)
+		} else {
+			fmt.Printf("Proof #%d:   %s\n", idx, proof.Value)
+		}
+	}
+
+	return nil
+}
+
+func importList(config tf2bdd.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	author := flags.Int64("author", 0, "Discord user id recorded as the author")
+	dryRun := flags.Bool("dry-run", false, "Show the changes without importing them")
+
+	if errParse := flags.Parse(args); errParse != nil {
+		return errParse
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("import requires a file")
+	}
+
+	body, errRead := os.ReadFile(flags.Arg(0))
+	if errRead != nil {
+		return errors.Join(errRead, errors.New("failed to read file"))
+	}
+
+	var playerList tf2bdd.PlayerListRoot
+	if errDecode := json.Unmarshal(body, &playerList); errDecode != nil {
+		return errors.Join(errDecode, errors.New("failed to decode file"))
+	}
+
+	database, errDatabase := openDatabase(config)
+	if errDatabase != nil {
+		return errDatabase
+	}
+
+	defer closeDatabase(database)
+
+	if *dryRun {
+		diff, errDiff := tf2bdd.DiffImport(context.Background(), database, config, playerList)
+		if errDiff != nil {
+			return errDiff
+		}
+
+		fmt.Printf("New: %d\nAlready present: %d\nAttribute conflicts: %d\nPreviously deleted: %d\nSkipped: %d\n",
+			len(diff.New), len(diff.Present), len(diff.Conflicts), len(diff.Deleted), diff.Skipped)
+
+		for _, conflict := range diff.Conflicts {
+			fmt.Printf("%s imported: %s, listed: %s\n", conflict.Player.SteamID.String(),
+				strings.Join(conflict.Player.Attributes, ", "), strings.Join(conflict.Existing, ", "))
+		}
+
+		return nil
+	}
+
+	added, errImport := tf2bdd.ImportPlayerList(context.Background(), database, config, playerList, *author)
+	if errImport != nil {
+		return errImport
+	}
+
+	fmt.Printf("Loaded %d new players\n", added)
+
+	return nil
+}
+
+func exportList(config tf2bdd.Config, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	upstream := flags.Bool("upstream", true, "Include entries merged from upstream lists")
+
+	if errParse := flags.Parse(args); errParse != nil {
+		return errParse
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("export requires a file")
+	}
+
+	database, errDatabase := openDatabase(config)
+	if errDatabase != nil {
+		return errDatabase
+	}
+
+	defer closeDatabase(database)
+
+	playerList, errExport := tf2bdd.ExportPlayerList(context.Background(), database, config, *upstream)
+	if errExport != nil {
+		return errExport
+	}
+
+	var output io.Writer = os.Stdout
+
+	if flags.Arg(0) != "-" {
+		file, errCreate := os.Create(flags.Arg(0))
+		if errCreate != nil {
+			return errors.Join(errCreate, errors.New("failed to create file"))
+		}
+
+		defer func() {
+			if errClose := file.Close(); errClose != nil {
+				slog.Error("failed to close file", slog.String("error", errClose.Error()))
+			}
+		}()
+
+		output = file
+	}
+
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+
+	if errEncode := encoder.Encode(playerList); errEncode != nil {
+		return errors.Join(errEncode, errors.New("failed to encode list"))
+	}
+
+	return nil
+}
+
+func syncUpstreams(config tf2bdd.Config) error {
+	if len(config.Upstreams) == 0 {
+		return errors.New("no upstreams are configured")
+	}
+
+	database, errDatabase := openDatabase(config)
+	if errDatabase != nil {
+		return errDatabase
+	}
+
+	defer closeDatabase(database)
+
+	results, errSync := tf2bdd.SyncUpstreams(context.Background(), database, config)
+
+	for _, upstream := range config.Upstreams {
+		if result, found := results[upstream.Name]; found {
+			fmt.Printf("%s: %d added, %d updated, %d removed\n", upstream.Name, result.Added, result.Updated, result.Removed)
+		}
+	}
+
+	return errSync
+}
+
+func manageTokens(config tf2bdd.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("token requires one of: create, list, revoke")
+	}
+
+	action := args[0]
+
+	flags := flag.NewFlagSet("token "+action, flag.ContinueOnError)
+	scope := flags.String("scope", string(tf2bdd.ScopeRead), "Token scope, one of: read, write, admin")
+	label := flags.String("label", "", "Author label recorded for changes made with the token")
+
+	if errParse := flags.Parse(args[1:]); errParse != nil {
+		return errParse
+	}
+
+	database, errDatabase := openDatabase(config)
+	if errDatabase != nil {
+		return errDatabase
+	}
+
+	defer closeDatabase(database)
+
+	ctx := context.Background()
+
+	switch action {
+	case "create":
+		token, value, errCreate := tf2bdd.CreateAPIToken(ctx, database, tf2bdd.TokenScope(*scope), *label)
+		if errCreate != nil {
+			return errCreate
+		}
+
+		fmt.Printf("Created token #%d (%s) for: %s\n", token.TokenID, token.Scope, token.AuthorLabel)
+		fmt.Printf("Token: %s\n", value)
+		fmt.Println("Store the token securely, it cannot be shown again.")
+	case "list":
+		tokens, errTokens := tf2bdd.GetAPITokens(ctx, database)
+		if errTokens != nil {
+			return errTokens
+		}
+
+		for _, token := range tokens {
+			status := "active"
+			if !token.RevokedOn.IsZero() {
+				status = "revoked on " + token.RevokedOn.Format(time.DateTime)
+			}
+
+			fmt.Printf("#%d %-5s %s (created on %s, %s)\n", token.TokenID, token.Scope, token.AuthorLabel,
+				token.CreatedOn.Format(time.DateTime), status)
+		}
+	case "revoke":
+		if flags.NArg() != 1 {
+			return errors.New("revoke requires a token id")
+		}
+
+		tokenID, errID := strconv.ParseInt(flags.Arg(0), 10, 64)
+		if errID != nil {
+			return fmt.Errorf("invalid token id: %s", flags.Arg(0))
+		}
+
+		if errRevoke := tf2bdd.RevokeAPIToken(ctx, database, tokenID); errRevoke != nil {
+			if errors.Is(errRevoke, tf2bdd.ErrNotFound) {
+				return fmt.Errorf("no active token with id: %d", tokenID)
+			}
+
+			return errRevoke
+		}
+
+		fmt.Printf("Revoked token #%d\n", tokenID)
+	default:
+		return fmt.Errorf("unknown token action: %s", action)
+	}
+
+	return nil
+}
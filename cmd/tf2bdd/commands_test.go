@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/leighmacdonald/steamid/v4/steamid"
+	"github.com/leighmacdonald/tf2bdd/tf2bdd"
+	"github.com/stretchr/testify/require"
+)
+
+// testConfig returns a config using a new database in a temporary directory.
+func testConfig(t *testing.T) tf2bdd.Config {
+	t.Helper()
+
+	return tf2bdd.Config{
+		DatabasePath:    filepath.Join(t.TempDir(), "db.sqlite"),
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		KnownAttributes: []string{"cheater", "suspicious"},
+	}
+}
+
+// getPlayer loads a player from the database of the config.
+func getPlayer(t *testing.T, config tf2bdd.Config, sid steamid.SteamID) (tf2bdd.Player, error) {
+	t.Helper()
+
+	database, errDatabase := openDatabase(config)
+	require.NoError(t, errDatabase)
+
+	defer closeDatabase(database)
+
+	return tf2bdd.GetPlayer(context.Background(), database, sid)
+}
+
+func TestAddPlayer(t *testing.T) {
+	config := testConfig(t)
+	sid := steamid.New(76561197960287930)
+
+	require.ErrorContains(t, addPlayer(config, []string{}), "requires a steam id")
+	require.ErrorContains(t, addPlayer(config, []string{"invalid"}), "invalid steam id")
+	require.Error(t, addPlayer(config, []string{"-attrs", "chaeter", sid.String()}))
+
+	require.NoError(t, addPlayer(config, []string{"-attrs", "Cheater, suspicious", "-name", "bot",
+		"-proof", "https://example.com/demo | round 1", "-author", "1", sid.String()}))
+	require.ErrorContains(t, addPlayer(config, []string{sid.String()}), "duplicate steam id")
+
+	player, errPlayer := getPlayer(t, config, sid)
+	require.NoError(t, errPlayer)
+	require.Equal(t, []string{"cheater", "suspicious"}, player.Attributes)
+	require.Equal(t, "bot", player.LastSeen.PlayerName)
+	require.Len(t, player.Proof, 1)
+	require.Equal(t, "https://example.com/demo", player.Proof[0].Value)
+	require.Equal(t, "round 1", player.Proof[0].Note)
+	require.Equal(t, int64(1), player.Author)
+	require.True(t, player.Confirmed)
+
+	// The first known attribute is used by default, and entries go through confirmation when required.
+	config.RequiredConfirmations = 2
+	unconfirmed := steamid.New(76561197960265729)
+	require.NoError(t, addPlayer(config, []string{"-author", "1", unconfirmed.String()}))
+
+	player, errPlayer = getPlayer(t, config, unconfirmed)
+	require.NoError(t, errPlayer)
+	require.Equal(t, []string{"cheater"}, player.Attributes)
+	require.False(t, player.Confirmed)
+}
+
+func TestDeletePlayer(t *testing.T) {
+	config := testConfig(t)
+	sid := steamid.New(76561197960287930)
+
+	require.ErrorContains(t, deletePlayer(config, []string{sid.String()}), "does not exist")
+	require.NoError(t, addPlayer(config, []string{sid.String()}))
+	require.NoError(t, deletePlayer(config, []string{"-author", "1", sid.String()}))
+	require.ErrorContains(t, deletePlayer(config, []string{sid.String()}), "does not exist")
+
+	_, errPlayer := getPlayer(t, config, sid)
+	require.ErrorIs(t, errPlayer, tf2bdd.ErrNotFound)
+}
+
+func TestCheckPlayer(t *testing.T) {
+	config := testConfig(t)
+	sid := steamid.New(76561197960287930)
+
+	require.ErrorContains(t, checkPlayer(config, []string{}), "requires a steam id")
+	require.ErrorContains(t, checkPlayer(config, []string{sid.String()}), "does not exist")
+	require.NoError(t, addPlayer(config, []string{sid.String()}))
+	require.NoError(t, checkPlayer(config, []string{sid.String()}))
+	require.NoError(t, checkPlayer(config, []string{"[U:1:22202]"}))
+}
+
+func TestImportExportList(t *testing.T) {
+	config := testConfig(t)
+	dir := t.TempDir()
+	listPath := filepath.Join(dir, "list.json")
+
+	require.NoError(t, addPlayer(config, []string{"-attrs", "suspicious", "76561197960287930"}))
+	require.NoError(t, exportList(config, []string{listPath}))
+
+	body, errRead := os.ReadFile(listPath)
+	require.NoError(t, errRead)
+
+	var exported tf2bdd.PlayerListRoot
+	require.NoError(t, json.Unmarshal(body, &exported))
+	require.Equal(t, "test title", exported.ListSource.Title)
+	require.Len(t, exported.Players, 1)
+	require.Equal(t, []string{"suspicious"}, exported.Players[0].Attributes)
+
+	imported := tf2bdd.PlayerListRoot{Players: []tf2bdd.Player{
+		{SteamID: steamid.New(76561197960287930), Attributes: []string{"suspicious"}},
+		{SteamID: steamid.New(76561197960265729), Attributes: []string{"cheater"}},
+	}}
+
+	importBody, errEncode := json.Marshal(imported)
+	require.NoError(t, errEncode)
+
+	importPath := filepath.Join(dir, "import.json")
+	require.NoError(t, os.WriteFile(importPath, importBody, 0o600))
+
+	require.ErrorContains(t, importList(config, []string{}), "requires a file")
+
+	// Dry runs leave the database untouched.
+	require.NoError(t, importList(config, []string{"-dry-run", importPath}))
+	_, errPlayer := getPlayer(t, config, steamid.New(76561197960265729))
+	require.ErrorIs(t, errPlayer, tf2bdd.ErrNotFound)
+
+	require.NoError(t, importList(config, []string{importPath}))
+	player, errPlayer := getPlayer(t, config, steamid.New(76561197960265729))
+	require.NoError(t, errPlayer)
+	require.Equal(t, []string{"cheater"}, player.Attributes)
+
+	require.NoError(t, exportList(config, []string{listPath}))
+
+	body, errRead = os.ReadFile(listPath)
+	require.NoError(t, errRead)
+	require.NoError(t, json.Unmarshal(body, &exported))
+	require.Len(t, exported.Players, 2)
+}
@@ -11,6 +11,7 @@
 	"syscall"
 	"time"
 
+	"github.com/bwmarrin/discordgo"
 	"github.com/leighmacdonald/steamid/v4/steamid"
 	"github.com/leighmacdonald/tf2bdd/tf2bdd"
 	_ "github.com/ncruces/go-sqlite3/driver"
@@ -20,7 +21,7 @@
 var version = "dev"
 
 func main() {
-	if err := run(); err != nil {
+	if err := run(os.Args[1:]); err != nil {
 		slog.Error("error returned", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
@@ -28,21 +29,80 @@ func main() {
 	os.Exit(0)
 }
 
-func run() error {
-	slog.Info("Starting tf2bdd", slog.String("version", version))
+const usage = `Usage: tf2bdd [command] [options]
+
+Commands:
+  serve                          Run the bot and/or http server, depending on the configured mode (default)
+  validate-config                Check the configuration file for errors
+  migrate up                     Apply all pending database migrations
+  migrate down [-steps n]        Revert the last n database migrations
+  migrate version                Show the current database schema version
+  add [options] <steamid>        Add a player
+  del [options] <steamid>        Remove a player
+  check <steamid>                Show the details of a player
+  import [options] <file>        Import the players of a playerlist json file
+  export [options] <file>        Export the list served at /v1/steamids, use - to write to stdout
+  sync                           Fetch and merge all upstream lists once
+  token create [options]         Create an api token
+  token list                     List all api tokens
+  token revoke <id>              Revoke an api token
+
+Run "tf2bdd <command> -h" for the options of a command.
+`
+
+func run(args []string) error {
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	if command == "help" || command == "-h" || command == "--help" {
+		fmt.Print(usage)
+
+		return nil
+	}
 
 	config, errConfig := tf2bdd.ReadConfig()
 	if errConfig != nil {
 		return errConfig
 	}
 
+	switch command {
+	case "serve":
+		return serve(config)
+	case "validate-config":
+		return validateConfig(config)
+	case "migrate":
+		return migrateDB(config, args)
+	case "add":
+		return addPlayer(config, args)
+	case "del":
+		return deletePlayer(config, args)
+	case "check":
+		return checkPlayer(config, args)
+	case "import":
+		return importList(config, args)
+	case "export":
+		return exportList(config, args)
+	case "sync":
+		return syncUpstreams(config)
+	case "token":
+		return manageTokens(config, args)
+	}
+
+	fmt.Print(usage)
+
+	return fmt.Errorf("unknown command: %s", command)
+}
+
+func serve(config tf2bdd.Config) error {
+	slog.Info("Starting tf2bdd", slog.String("version", version))
+
 	if errValidate := tf2bdd.ValidateConfig(config); errValidate != nil {
 		return fmt.Errorf("config file validation error: %w", errValidate)
 	}
 
-	if errSetKey := steamid.SetKey(config.SteamKey); errSetKey != nil {
-		return errSetKey
-	}
+	slog.Info("Using run mode", slog.String("mode", string(config.Mode)))
 
 	database, errDatabase := tf2bdd.OpenDB(config.DatabasePath)
 	if errDatabase != nil {
@@ -56,40 +116,61 @@ func run() error {
 	appCtx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	httpServer := tf2bdd.CreateHTTPServer(tf2bdd.CreateRouter(database, config), config.ListenAddr())
+	var httpServer *http.Server
+	if config.RunsHTTP() {
+		httpServer = tf2bdd.CreateHTTPServer(tf2bdd.CreateRouter(database, config), config.ListenAddr())
 
-	discordBot, errBot := tf2bdd.NewBot(config.DiscordBotToken)
-	if errBot != nil {
-		return errBot
+		slog.Info("Listening on", slog.String("addr", config.ListenAddr()))
+
+		go func() {
+			if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Listener error", slog.String("error", err.Error()))
+			}
+		}()
 	}
 
-	slog.Info("Add bot", slog.String("link", tf2bdd.DiscordAddURL(config.DiscordClientID)))
-	slog.Info("Make sure you enable \"Message Content Intent\" on your discord config under the Bot settings via discord website")
-	slog.Info("Listening on", slog.String("addr", config.ListenAddr()))
+	var discordBot *discordgo.Session
+	if config.RunsBot() {
+		if errSetKey := steamid.SetKey(config.SteamKey); errSetKey != nil {
+			return errSetKey
+		}
 
-	go func() {
-		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			slog.Error("Listener error", slog.String("error", err.Error()))
+		var errBot error
+		if discordBot, errBot = tf2bdd.NewBot(config.DiscordBotToken); errBot != nil {
+			return errBot
 		}
-	}()
 
-	if errBotStart := tf2bdd.StartBot(appCtx, discordBot, database, config); errBotStart != nil {
-		slog.Error("discord bot error", slog.String("error", errBotStart.Error()))
+		slog.Info("Add bot", slog.String("link", tf2bdd.DiscordAddURL(config.DiscordClientID)))
+		slog.Info("Make sure you enable \"Message Content Intent\" on your discord config under the Bot settings via discord website")
+
+		// Only the bot modifies the database, so read-only mirrors do not purge, sync upstreams or
+		// fetch steam profiles.
+		go tf2bdd.PurgeDeletedWorker(appCtx, database, config.PurgeDeletedAfter)
+		go tf2bdd.UpstreamWorker(appCtx, database, config)
+		go tf2bdd.EnrichWorker(appCtx, database, config)
+
+		if errBotStart := tf2bdd.StartBot(appCtx, discordBot, database, config); errBotStart != nil {
+			slog.Error("discord bot error", slog.String("error", errBotStart.Error()))
+		}
 	}
 
 	<-appCtx.Done()
 
 	slog.Info("Shutting down")
 
-	if err := discordBot.Close(); err != nil {
-		slog.Error("Failed to properly shutdown discord client", slog.String("error", err.Error()))
+	if discordBot != nil {
+		if err := discordBot.Close(); err != nil {
+			slog.Error("Failed to properly shutdown discord client", slog.String("error", err.Error()))
+		}
 	}
 
-	cancelCtx, cancelHTTP := context.WithDeadline(context.Background(), time.Now().Add(10*time.Second))
-	defer cancelHTTP()
+	if httpServer != nil {
+		cancelCtx, cancelHTTP := context.WithDeadline(context.Background(), time.Now().Add(10*time.Second))
+		defer cancelHTTP()
 
-	if err := httpServer.Shutdown(cancelCtx); err != nil {
-		slog.Error("Failed to cleanly shutdown http service: %s", slog.String("error", err.Error()))
+		if err := httpServer.Shutdown(cancelCtx); err != nil {
+			slog.Error("Failed to cleanly shutdown http service: %s", slog.String("error", err.Error()))
+		}
 	}
 
 	return nil
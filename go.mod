@@ -3,13 +3,16 @@ module github.com/leighmacdonald/tf2bdd
 go 1.22
 
 require (
+	github.com/andybalholm/brotli v1.2.0
 	github.com/bwmarrin/discordgo v0.27.1
 	github.com/golang-migrate/migrate/v4 v4.17.0
+	github.com/klauspost/compress v1.18.0
 	github.com/leighmacdonald/steamid/v4 v4.0.2
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/ncruces/go-sqlite3 v0.13.0
 	github.com/spf13/viper v1.18.2
 	github.com/stretchr/testify v1.9.0
+	github.com/tetratelabs/wazero v1.7.0
 )
 
 require (
@@ -35,7 +38,6 @@ require (
 	github.com/spf13/cast v1.6.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/subosito/gotenv v1.6.0 // indirect
-	github.com/tetratelabs/wazero v1.7.0 // indirect
 	go.uber.org/atomic v1.9.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	golang.org/x/crypto v0.21.0 // indirect
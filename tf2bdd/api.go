@@ -0,0 +1,344 @@
+package tf2bdd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leighmacdonald/steamid/v4/steamid"
+)
+
+// maxRequestSize limits the size of api request bodies.
+const maxRequestSize = 1 << 20
+
+// apiPlayer is the api representation of a player. Unlike the playerlist format, it includes the
+// entries metadata and full proof details.
+type apiPlayer struct {
+	SteamID    steamid.SteamID `json:"steamid"`
+	Attributes []string        `json:"attributes"`
+	LastSeen   LastSeen        `json:"last_seen"`
+	Author     int64           `json:"author,string"`
+	CreatedOn  time.Time       `json:"created_on"`
+	Confirmed  bool            `json:"confirmed"`
+	Source     string          `json:"source"`
+	ClaimedBy  int64           `json:"claimed_by,string"`
+	Proof      []proofSnapshot `json:"proof"`
+	Steam      *SteamProfile   `json:"steam,omitempty"`
+}
+
+// newAPIPlayer returns a pointer so the steam id, which only implements json.Marshaler on its pointer,
+// is encoded as a string.
+func newAPIPlayer(player Player) *apiPlayer {
+	proof := make([]proofSnapshot, len(player.Proof))
+	for idx, entry := range player.Proof {
+		proof[idx] = proofSnapshot(entry)
+	}
+
+	return &apiPlayer{
+		SteamID:    player.SteamID,
+		Attributes: player.Attributes,
+		LastSeen:   player.LastSeen,
+		Author:     player.Author,
+		CreatedOn:  player.CreatedOn,
+		Confirmed:  player.Confirmed,
+		Source:     player.Source,
+		ClaimedBy:  player.ClaimedBy,
+		Proof:      proof,
+		Steam:      player.Steam,
+	}
+}
+
+type apiProofRequest struct {
+	Value string `json:"value"`
+	Note  string `json:"note"`
+}
+
+func (req apiProofRequest) entry() ProofEntry {
+	value := strings.TrimSpace(req.Value)
+
+	return ProofEntry{Value: value, Note: strings.TrimSpace(req.Note), Kind: proofKind(value)}
+}
+
+type apiCreatePlayerRequest struct {
+	SteamID    string            `json:"steamid"`
+	Attributes []string          `json:"attributes"`
+	Name       string            `json:"name"`
+	Proof      []apiProofRequest `json:"proof"`
+}
+
+// apiUpdatePlayerRequest changes only the fields that are provided.
+type apiUpdatePlayerRequest struct {
+	Attributes *[]string `json:"attributes"`
+	Name       *string   `json:"name"`
+}
+
+func writeJSON(writer http.ResponseWriter, status int, value any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+
+	if errEncode := json.NewEncoder(writer).Encode(value); errEncode != nil {
+		slog.Error("failed to encode response", slog.String("error", errEncode.Error()))
+	}
+}
+
+func writeError(writer http.ResponseWriter, status int, message string) {
+	writeJSON(writer, status, map[string]string{"error": message})
+}
+
+// writeEntryError responds with the status matching the error returned by an entry operation.
+func writeEntryError(writer http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(writer, http.StatusNotFound, "steam id does not exist in database")
+	case errors.Is(err, ErrDuplicate):
+		writeError(writer, http.StatusConflict, "steam id already exists in database")
+	case errors.Is(err, ErrDuplicateProof):
+		writeError(writer, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrUnknownAttribute):
+		writeError(writer, http.StatusBadRequest, err.Error())
+	default:
+		slog.Error("Failed to handle api request", slog.String("error", err.Error()))
+		writeError(writer, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+func decodeRequest(writer http.ResponseWriter, request *http.Request, value any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxRequestSize))
+	decoder.DisallowUnknownFields()
+
+	if errDecode := decoder.Decode(value); errDecode != nil {
+		writeError(writer, http.StatusBadRequest, "invalid request body")
+
+		return false
+	}
+
+	return true
+}
+
+func pathSteamID(writer http.ResponseWriter, request *http.Request) (steamid.SteamID, bool) {
+	sid := steamid.New(request.PathValue("steamid"))
+	if !sid.Valid() {
+		writeError(writer, http.StatusBadRequest, "invalid steam id")
+
+		return sid, false
+	}
+
+	return sid, true
+}
+
+// requireToken only allows requests with a bearer token that grants the required scope. Changes made
+// by the request are recorded in the audit log with the tokens author label.
+func requireToken(database *sql.DB, scope TokenScope, next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		value, found := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+		if !found || value == "" {
+			writer.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(writer, http.StatusUnauthorized, "missing api token")
+
+			return
+		}
+
+		token, errToken := getActiveAPIToken(request.Context(), database, value)
+		if errToken != nil {
+			if !errors.Is(errToken, ErrNotFound) {
+				slog.Error("Failed to load api token", slog.String("error", errToken.Error()))
+			}
+
+			writer.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(writer, http.StatusUnauthorized, "invalid api token")
+
+			return
+		}
+
+		if !token.Scope.allows(scope) {
+			writeError(writer, http.StatusForbidden, "token scope does not allow this action")
+
+			return
+		}
+
+		next(writer, request.WithContext(withAuthorLabel(request.Context(), token.AuthorLabel)))
+	}
+}
+
+func handleCreatePlayer(database *sql.DB, config Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var req apiCreatePlayerRequest
+		if !decodeRequest(writer, request, &req) {
+			return
+		}
+
+		sid := steamid.New(req.SteamID)
+		if !sid.Valid() {
+			writeError(writer, http.StatusBadRequest, "invalid steam id")
+
+			return
+		}
+
+		player := Player{
+			SteamID:    sid,
+			Attributes: req.Attributes,
+			LastSeen:   LastSeen{PlayerName: req.Name, Time: time.Now().Unix()},
+			Proof:      Proof{},
+		}
+
+		for _, proofReq := range req.Proof {
+			entry := proofReq.entry()
+			if entry.Value == "" {
+				writeError(writer, http.StatusBadRequest, "empty proof value")
+
+				return
+			}
+
+			player.Proof = append(player.Proof, entry)
+		}
+
+		added, errAdd := CreateEntry(request.Context(), database, config, player, config.KnownAttributes[0], 0)
+		if errAdd != nil {
+			writeEntryError(writer, errAdd)
+
+			return
+		}
+
+		writer.Header().Set("Location", "/v1/players/"+sid.String())
+		writeJSON(writer, http.StatusCreated, newAPIPlayer(added))
+	}
+}
+
+func handleGetPlayer(database *sql.DB) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		sid, valid := pathSteamID(writer, request)
+		if !valid {
+			return
+		}
+
+		player, errPlayer := getPlayer(request.Context(), database, sid)
+		if errPlayer != nil {
+			writeEntryError(writer, errPlayer)
+
+			return
+		}
+
+		writeJSON(writer, http.StatusOK, newAPIPlayer(player))
+	}
+}
+
+func handleUpdatePlayer(database *sql.DB, config Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		sid, valid := pathSteamID(writer, request)
+		if !valid {
+			return
+		}
+
+		var req apiUpdatePlayerRequest
+		if !decodeRequest(writer, request, &req) {
+			return
+		}
+
+		player, errPlayer := getPlayer(request.Context(), database, sid)
+		if errPlayer != nil {
+			writeEntryError(writer, errPlayer)
+
+			return
+		}
+
+		if req.Attributes != nil {
+			attrs, errAttrs := NormalizeAttributes(config.KnownAttributes, *req.Attributes)
+			if errAttrs != nil {
+				writeEntryError(writer, errAttrs)
+
+				return
+			}
+
+			if len(attrs) == 0 {
+				writeError(writer, http.StatusBadRequest, "entry must keep at least one attribute")
+
+				return
+			}
+
+			player.Attributes = attrs
+		}
+
+		if req.Name != nil {
+			player.LastSeen.PlayerName = *req.Name
+		}
+
+		if errUpdate := updatePlayer(request.Context(), database, player, 0); errUpdate != nil {
+			writeEntryError(writer, errUpdate)
+
+			return
+		}
+
+		updated, errUpdated := getPlayer(request.Context(), database, sid)
+		if errUpdated != nil {
+			writeEntryError(writer, errUpdated)
+
+			return
+		}
+
+		writeJSON(writer, http.StatusOK, newAPIPlayer(updated))
+	}
+}
+
+func handleDeletePlayer(database *sql.DB) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		sid, valid := pathSteamID(writer, request)
+		if !valid {
+			return
+		}
+
+		if errDrop := DropPlayer(request.Context(), database, sid, 0); errDrop != nil {
+			writeEntryError(writer, errDrop)
+
+			return
+		}
+
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func handleAddProof(database *sql.DB) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		sid, valid := pathSteamID(writer, request)
+		if !valid {
+			return
+		}
+
+		var req apiProofRequest
+		if !decodeRequest(writer, request, &req) {
+			return
+		}
+
+		entry := req.entry()
+		if entry.Value == "" {
+			writeError(writer, http.StatusBadRequest, "empty proof value")
+
+			return
+		}
+
+		player, errPlayer := getPlayer(request.Context(), database, sid)
+		if errPlayer != nil {
+			writeEntryError(writer, errPlayer)
+
+			return
+		}
+
+		if errAppend := appendProof(request.Context(), database, player, Proof{entry}, 0); errAppend != nil {
+			writeEntryError(writer, errAppend)
+
+			return
+		}
+
+		updated, errUpdated := getPlayer(request.Context(), database, sid)
+		if errUpdated != nil {
+			writeEntryError(writer, errUpdated)
+
+			return
+		}
+
+		writeJSON(writer, http.StatusCreated, newAPIPlayer(updated))
+	}
+}
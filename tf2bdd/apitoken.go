@@ -0,0 +1,182 @@
+package tf2bdd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var ErrInvalidScope = errors.New("invalid token scope")
+
+// TokenScope controls which api endpoints a token can use. Each scope includes the permissions of the
+// scopes below it.
+type TokenScope string
+
+const (
+	// ScopeRead allows looking up players.
+	ScopeRead TokenScope = "read"
+	// ScopeWrite allows adding and editing players.
+	ScopeWrite TokenScope = "write"
+	// ScopeAdmin allows deleting players.
+	ScopeAdmin TokenScope = "admin"
+)
+
+var scopeLevels = map[TokenScope]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+
+// allows returns true if the scope grants the permissions of the required scope.
+func (scope TokenScope) allows(required TokenScope) bool {
+	return scopeLevels[scope] >= scopeLevels[required]
+}
+
+// APIToken grants access to the http api. Only the hash of the token value is stored.
+type APIToken struct {
+	TokenID     int64
+	Scope       TokenScope
+	AuthorLabel string
+	CreatedOn   time.Time
+	RevokedOn   time.Time
+}
+
+// tokenPrefix makes tokens easy to identify, eg: by secret scanners.
+const tokenPrefix = "tf2bdd_"
+
+func hashToken(value string) string {
+	hash := sha256.Sum256([]byte(value))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// CreateAPIToken creates a new token, returning it along with its value. The value cannot be retrieved later.
+func CreateAPIToken(ctx context.Context, database *sql.DB, scope TokenScope, authorLabel string) (APIToken, string, error) {
+	if _, found := scopeLevels[scope]; !found {
+		return APIToken{}, "", fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+	}
+
+	if authorLabel == "" {
+		return APIToken{}, "", errors.New("author label cannot be empty")
+	}
+
+	secret := make([]byte, 32)
+	if _, errRead := rand.Read(secret); errRead != nil {
+		return APIToken{}, "", errors.Join(errRead, errors.New("failed to generate token"))
+	}
+
+	value := tokenPrefix + hex.EncodeToString(secret)
+	token := APIToken{
+		Scope:       scope,
+		AuthorLabel: authorLabel,
+		CreatedOn:   time.Unix(time.Now().Unix(), 0),
+	}
+
+	const query = `
+		INSERT INTO api_token (token_hash, scope, author_label, created_on)
+		VALUES (?, ?, ?, ?)
+		RETURNING token_id`
+
+	if errInsert := database.QueryRowContext(ctx, query, hashToken(value), token.Scope, token.AuthorLabel,
+		token.CreatedOn.Unix()).Scan(&token.TokenID); errInsert != nil {
+		return APIToken{}, "", errors.Join(errInsert, errors.New("failed to create token"))
+	}
+
+	return token, value, nil
+}
+
+const apiTokenColumns = `token_id, scope, author_label, created_on, revoked_on`
+
+func scanAPIToken(row rowScanner) (APIToken, error) {
+	var (
+		token     APIToken
+		createdOn int64
+		revokedOn int64
+	)
+
+	if errScan := row.Scan(&token.TokenID, &token.Scope, &token.AuthorLabel, &createdOn, &revokedOn); errScan != nil {
+		return APIToken{}, dbErr(errScan)
+	}
+
+	token.CreatedOn = time.Unix(createdOn, 0)
+
+	if revokedOn > 0 {
+		token.RevokedOn = time.Unix(revokedOn, 0)
+	}
+
+	return token, nil
+}
+
+// getActiveAPIToken returns the token matching the value, unless it has been revoked.
+func getActiveAPIToken(ctx context.Context, database querier, value string) (APIToken, error) {
+	const query = `SELECT ` + apiTokenColumns + ` FROM api_token WHERE token_hash = ? AND revoked_on = 0`
+
+	return scanAPIToken(database.QueryRowContext(ctx, query, hashToken(value)))
+}
+
+// GetAPITokens returns all tokens, including revoked ones.
+func GetAPITokens(ctx context.Context, database *sql.DB) ([]APIToken, error) {
+	rows, errQuery := database.QueryContext(ctx, `SELECT `+apiTokenColumns+` FROM api_token ORDER BY token_id`)
+	if errQuery != nil {
+		return nil, errors.Join(errQuery, errors.New("failed to load tokens"))
+	}
+
+	defer func() {
+		if errClose := rows.Close(); errClose != nil {
+			slog.Error("Failed to close rows handle", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	var tokens []APIToken
+
+	for rows.Next() {
+		token, errScan := scanAPIToken(rows)
+		if errScan != nil {
+			return nil, errors.Join(errScan, errors.New("error scanning token row"))
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	if rows.Err() != nil {
+		return nil, errors.Join(rows.Err(), errors.New("error reading token rows"))
+	}
+
+	return tokens, nil
+}
+
+// RevokeAPIToken permanently disables a token.
+func RevokeAPIToken(ctx context.Context, database *sql.DB, tokenID int64) error {
+	result, errExec := database.ExecContext(ctx, `UPDATE api_token SET revoked_on = ? WHERE token_id = ? AND revoked_on = 0`,
+		time.Now().Unix(), tokenID)
+	if errExec != nil {
+		return errors.Join(errExec, errors.New("failed to revoke token"))
+	}
+
+	affected, errAffected := result.RowsAffected()
+	if errAffected != nil {
+		return errors.Join(errAffected, errors.New("failed to revoke token"))
+	}
+
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+type authorLabelKey struct{}
+
+// withAuthorLabel attaches a label describing the author of changes made with the context. It is
+// recorded in the audit log for changes that are not made by a discord user, such as api tokens.
+func withAuthorLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, authorLabelKey{}, label)
+}
+
+func authorLabel(ctx context.Context) string {
+	label, _ := ctx.Value(authorLabelKey{}).(string)
+
+	return label
+}
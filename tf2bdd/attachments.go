@@ -0,0 +1,228 @@
+package tf2bdd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	ErrAttachmentSize = errors.New("attachment exceeds maximum size")
+	ErrAttachmentType = errors.New("attachment type not allowed")
+	reAttachmentHash  = regexp.MustCompile(`^[a-f0-9]{64}$`)
+)
+
+// attachmentPath returns the content addressed path of a stored attachment. Files are sharded into
+// sub directories using the first 2 characters of their hash.
+func attachmentPath(dir string, hash string) string {
+	return filepath.Join(dir, hash[:2], hash)
+}
+
+// sniffLen is the amount of data used by http.DetectContentType.
+const sniffLen = 512
+
+const (
+	// proofWriteTimeout is the write timeout of the http server, which proof downloads extend.
+	proofWriteTimeout = 5 * time.Second
+	// proofMinTransferRate is the slowest transfer rate, in bytes per second, that proof downloads are
+	// given time for.
+	proofMinTransferRate = 64 * 1024
+)
+
+// proofWriteDeadline returns how long serving a proof file of the size may take. The server wide write
+// timeout is too short for the larger attachments, such as demos and videos.
+func proofWriteDeadline(size int64) time.Duration {
+	return proofWriteTimeout + time.Duration(size/proofMinTransferRate)*time.Second
+}
+
+// storeAttachment downloads a discord attachment into the proof directory, returning the sha256 hash
+// that it is stored under. The content type is determined from the file contents rather than trusting
+// the value reported by discord, as that is the type it will be served with.
+func storeAttachment(ctx context.Context, client *http.Client, config Config, attachment *discordgo.MessageAttachment) (string, error) {
+	if int64(attachment.Size) > config.ProofMaxSize {
+		return "", fmt.Errorf("%w: %s (%d > %d bytes)", ErrAttachmentSize, attachment.Filename, attachment.Size, config.ProofMaxSize)
+	}
+
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, attachment.URL, nil)
+	if errReq != nil {
+		return "", errors.Join(errReq, errors.New("failed to setup http request"))
+	}
+
+	resp, errResp := client.Do(req)
+	if errResp != nil {
+		return "", errors.Join(errResp, errors.New("failed to download attachment"))
+	}
+
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			slog.Error("failed to close body", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download attachment, invalid status code: %d", resp.StatusCode)
+	}
+
+	if errMkdir := os.MkdirAll(config.ProofDir, 0o755); errMkdir != nil {
+		return "", errors.Join(errMkdir, errors.New("failed to create proof directory"))
+	}
+
+	tempFile, errTemp := os.CreateTemp(config.ProofDir, "upload-*")
+	if errTemp != nil {
+		return "", errors.Join(errTemp, errors.New("failed to create temporary file"))
+	}
+
+	defer func() {
+		// Removing will fail once the file has been renamed into place, which is expected.
+		_ = os.Remove(tempFile.Name())
+	}()
+
+	hasher := sha256.New()
+	// Read one byte past the limit so oversized files can be detected.
+	body := io.LimitReader(resp.Body, config.ProofMaxSize+1)
+
+	written, errCopy := io.Copy(io.MultiWriter(tempFile, hasher), body)
+	if errClose := tempFile.Close(); errClose != nil {
+		return "", errors.Join(errClose, errors.New("failed to close temporary file"))
+	}
+
+	if errCopy != nil {
+		return "", errors.Join(errCopy, errors.New("failed to download attachment"))
+	}
+
+	if written > config.ProofMaxSize {
+		return "", fmt.Errorf("%w: %s (> %d bytes)", ErrAttachmentSize, attachment.Filename, config.ProofMaxSize)
+	}
+
+	contentType, errType := detectFileContentType(tempFile.Name())
+	if errType != nil {
+		return "", errType
+	}
+
+	if !slices.Contains(config.ProofAllowedTypes, contentType) {
+		return "", fmt.Errorf("%w: %s (%s)", ErrAttachmentType, attachment.Filename, contentType)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	outPath := attachmentPath(config.ProofDir, hash)
+
+	if errMkdir := os.MkdirAll(filepath.Dir(outPath), 0o755); errMkdir != nil {
+		return "", errors.Join(errMkdir, errors.New("failed to create proof directory"))
+	}
+
+	if errRename := os.Rename(tempFile.Name(), outPath); errRename != nil {
+		return "", errors.Join(errRename, errors.New("failed to store attachment"))
+	}
+
+	return hash, nil
+}
+
+func detectFileContentType(path string) (string, error) {
+	file, errOpen := os.Open(path)
+	if errOpen != nil {
+		return "", errors.Join(errOpen, errors.New("failed to open file"))
+	}
+
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			slog.Error("failed to close file", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	head := make([]byte, sniffLen)
+
+	read, errRead := io.ReadFull(file, head)
+	if errRead != nil && !errors.Is(errRead, io.ErrUnexpectedEOF) && !errors.Is(errRead, io.EOF) {
+		return "", errors.Join(errRead, errors.New("failed to read file"))
+	}
+
+	contentType, _, _ := strings.Cut(http.DetectContentType(head[:read]), ";")
+
+	return contentType, nil
+}
+
+// storeAttachmentProof persists each attachment locally, returning proof entries pointing to our
+// own copies.
+func storeAttachmentProof(ctx context.Context, config Config, attachments []*discordgo.MessageAttachment, note string) (Proof, error) {
+	downloadCtx, cancel := context.WithTimeout(ctx, time.Minute*2)
+	defer cancel()
+
+	client := &http.Client{}
+	proof := make(Proof, 0, len(attachments))
+
+	for _, attachment := range attachments {
+		hash, errStore := storeAttachment(downloadCtx, client, config, attachment)
+		if errStore != nil {
+			return nil, errStore
+		}
+
+		proofURL, errURL := config.ProofURL(hash)
+		if errURL != nil {
+			return nil, errURL
+		}
+
+		entryNote := note
+		if entryNote == "" {
+			entryNote = attachment.Filename
+		}
+
+		proof = append(proof, ProofEntry{Value: proofURL, Note: entryNote, Kind: ProofKindAttachment})
+	}
+
+	return proof, nil
+}
+
+func handleGetProof(config Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		hash := request.PathValue("hash")
+		if !reAttachmentHash.MatchString(hash) {
+			http.NotFound(writer, request)
+
+			return
+		}
+
+		file, errOpen := os.Open(attachmentPath(config.ProofDir, hash))
+		if errOpen != nil {
+			http.NotFound(writer, request)
+
+			return
+		}
+
+		defer func() {
+			if errClose := file.Close(); errClose != nil {
+				slog.Error("failed to close file", slog.String("error", errClose.Error()))
+			}
+		}()
+
+		stat, errStat := file.Stat()
+		if errStat != nil {
+			http.Error(writer, "failed to read file", http.StatusInternalServerError)
+
+			return
+		}
+
+		if errDeadline := http.NewResponseController(writer).SetWriteDeadline(
+			time.Now().Add(proofWriteDeadline(stat.Size()))); errDeadline != nil && !errors.Is(errDeadline, http.ErrNotSupported) {
+			slog.Error("failed to extend write deadline", slog.String("error", errDeadline.Error()))
+		}
+
+		// Files are content addressed, so they never change.
+		writer.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		writer.Header().Set("X-Content-Type-Options", "nosniff")
+
+		http.ServeContent(writer, request, hash, stat.ModTime(), file)
+	}
+}
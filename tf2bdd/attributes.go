@@ -0,0 +1,99 @@
+package tf2bdd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrUnknownAttribute = errors.New("unknown attribute")
+
+// maxSuggestionDistance is the largest edit distance at which a known attribute is suggested
+// as a correction for an unknown one.
+const maxSuggestionDistance = 3
+
+// NormalizeAttributes lower cases and de-duplicates the attributes, returning an error for the first
+// one that is not part of the known set.
+func NormalizeAttributes(known []string, attributes []string) ([]string, error) {
+	normalized := make([]string, 0, len(attributes))
+
+	for _, attr := range attributes {
+		attr = strings.ToLower(strings.TrimSpace(attr))
+		if attr == "" || slices.Contains(normalized, attr) {
+			continue
+		}
+
+		if !slices.Contains(known, attr) {
+			if suggestion := closestAttribute(known, attr); suggestion != "" {
+				return nil, fmt.Errorf("%w: %s, did you mean: %s?", ErrUnknownAttribute, attr, suggestion)
+			}
+
+			return nil, fmt.Errorf("%w: %s, must be one of: %s", ErrUnknownAttribute, attr, strings.Join(known, ", "))
+		}
+
+		normalized = append(normalized, attr)
+	}
+
+	return normalized, nil
+}
+
+// filterAttributes lower cases and de-duplicates the attributes, silently dropping any that are not
+// part of the known set.
+func filterAttributes(known []string, attributes []string) []string {
+	filtered := make([]string, 0, len(attributes))
+
+	for _, attr := range attributes {
+		attr = strings.ToLower(strings.TrimSpace(attr))
+		if slices.Contains(known, attr) && !slices.Contains(filtered, attr) {
+			filtered = append(filtered, attr)
+		}
+	}
+
+	return filtered
+}
+
+// closestAttribute returns the known attribute with the smallest edit distance to attr, or an empty
+// string if none are close enough to be a likely typo.
+func closestAttribute(known []string, attr string) string {
+	var (
+		closest  string
+		distance = maxSuggestionDistance + 1
+	)
+
+	for _, candidate := range known {
+		if dist := levenshtein(attr, candidate); dist < distance {
+			closest = candidate
+			distance = dist
+		}
+	}
+
+	return closest
+}
+
+func levenshtein(source string, target string) int {
+	src, dst := []rune(source), []rune(target)
+	prev := make([]int, len(dst)+1)
+	curr := make([]int, len(dst)+1)
+
+	for idx := range prev {
+		prev[idx] = idx
+	}
+
+	for i := 1; i <= len(src); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(dst); j++ {
+			cost := 1
+			if src[i-1] == dst[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(dst)]
+}
@@ -0,0 +1,189 @@
+package tf2bdd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/leighmacdonald/steamid/v4/steamid"
+)
+
+type auditAction string
+
+const (
+	auditAdd     auditAction = "add"
+	auditUpdate  auditAction = "update"
+	auditDelete  auditAction = "delete"
+	auditImport  auditAction = "import"
+	auditRestore auditAction = "restore"
+	auditPurge   auditAction = "purge"
+	auditApprove auditAction = "approve"
+	auditConfirm auditAction = "confirm"
+	auditClaim   auditAction = "claim"
+)
+
+// proofSnapshot has the same fields as ProofEntry, but without its schema compatible json encoding.
+type proofSnapshot ProofEntry
+
+// playerSnapshot is the serialized form of a player stored in the audit log. Unlike the exported
+// list format, it includes the author, creation time and full proof details.
+type playerSnapshot struct {
+	Player
+	Author    int64           `json:"author"`
+	CreatedOn int64           `json:"created_on"`
+	Proof     []proofSnapshot `json:"proof"`
+}
+
+type AuditEntry struct {
+	AuditID     int64
+	SteamID     steamid.SteamID
+	Action      string
+	Author      int64
+	AuthorLabel string
+	CreatedOn   time.Time
+	Before      *Player
+	After       *Player
+}
+
+func encodeSnapshot(player *Player) (string, error) {
+	if player == nil {
+		return "", nil
+	}
+
+	proof := make([]proofSnapshot, len(player.Proof))
+	for idx, entry := range player.Proof {
+		proof[idx] = proofSnapshot(entry)
+	}
+
+	snapshot := *player
+	// Steam profiles are not part of the entry, so they are left out of the recorded changes.
+	snapshot.Steam = nil
+
+	// A pointer is used so the steam id, which only implements json.Marshaler on its pointer, is
+	// encoded as a string.
+	body, errMarshal := json.Marshal(&playerSnapshot{
+		Player:    snapshot,
+		Author:    player.Author,
+		CreatedOn: player.CreatedOn.Unix(),
+		Proof:     proof,
+	})
+	if errMarshal != nil {
+		return "", errors.Join(errMarshal, errors.New("failed to encode player snapshot"))
+	}
+
+	return string(body), nil
+}
+
+func decodeSnapshot(value string) (*Player, error) {
+	if value == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	var snapshot playerSnapshot
+	if errUnmarshal := json.Unmarshal([]byte(value), &snapshot); errUnmarshal != nil {
+		return nil, errors.Join(errUnmarshal, errors.New("failed to decode player snapshot"))
+	}
+
+	player := snapshot.Player
+	player.Author = snapshot.Author
+	player.CreatedOn = time.Unix(snapshot.CreatedOn, 0)
+	player.Proof = make(Proof, len(snapshot.Proof))
+
+	for idx, entry := range snapshot.Proof {
+		player.Proof[idx] = ProofEntry(entry)
+	}
+
+	return &player, nil
+}
+
+// addAuditEntry records a mutation of a player entry. It should be called within the same transaction
+// as the mutation itself. Any author label attached to the context is recorded along with the author.
+// The id of the entry is also used as the players change sequence, see setChangeSeq.
+func addAuditEntry(ctx context.Context, db querier, action auditAction, steamID steamid.SteamID, author int64, before *Player, after *Player) error {
+	const query = `
+		INSERT INTO audit_log (steamid, action, author, author_label, created_on, before, after)
+		VALUES(?, ?, ?, ?, ?, ?, ?)`
+
+	beforeValue, errBefore := encodeSnapshot(before)
+	if errBefore != nil {
+		return errBefore
+	}
+
+	afterValue, errAfter := encodeSnapshot(after)
+	if errAfter != nil {
+		return errAfter
+	}
+
+	result, errExec := db.ExecContext(ctx, query, steamID.Int64(), string(action), author, authorLabel(ctx),
+		time.Now().Unix(), beforeValue, afterValue)
+	if errExec != nil {
+		return errors.Join(errExec, errors.New("failed to write audit log entry"))
+	}
+
+	auditID, errID := result.LastInsertId()
+	if errID != nil {
+		return errors.Join(errID, errors.New("failed to read audit log entry id"))
+	}
+
+	return setChangeSeq(ctx, db, action, steamID, auditID)
+}
+
+// getAuditLog returns the most recent audit entries for a player, newest first.
+func getAuditLog(ctx context.Context, db querier, steamID steamid.SteamID, limit int) ([]AuditEntry, error) {
+	const query = `
+		SELECT audit_id, steamid, action, author, author_label, created_on, before, after
+		FROM audit_log
+		WHERE steamid = ?
+		ORDER BY audit_id DESC
+		LIMIT ?`
+
+	rows, errQuery := db.QueryContext(ctx, query, steamID.Int64(), limit)
+	if errQuery != nil {
+		return nil, errors.Join(errQuery, errors.New("failed to load audit log"))
+	}
+
+	defer func() {
+		if errClose := rows.Close(); errClose != nil {
+			slog.Error("Failed to close rows handle", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	var entries []AuditEntry
+
+	for rows.Next() {
+		var (
+			entry     AuditEntry
+			sid       int64
+			createdOn int64
+			before    string
+			after     string
+		)
+
+		if errScan := rows.Scan(&entry.AuditID, &sid, &entry.Action, &entry.Author, &entry.AuthorLabel, &createdOn,
+			&before, &after); errScan != nil {
+			return nil, errors.Join(errScan, errors.New("error scanning audit row"))
+		}
+
+		entry.SteamID = steamid.New(sid)
+		entry.CreatedOn = time.Unix(createdOn, 0)
+
+		var errDecode error
+		if entry.Before, errDecode = decodeSnapshot(before); errDecode != nil {
+			return nil, errDecode
+		}
+
+		if entry.After, errDecode = decodeSnapshot(after); errDecode != nil {
+			return nil, errDecode
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if rows.Err() != nil {
+		return nil, errors.Join(rows.Err(), errors.New("error reading audit rows"))
+	}
+
+	return entries, nil
+}
@@ -3,11 +3,9 @@
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"regexp"
 	"slices"
 	"strconv"
@@ -19,7 +17,7 @@
 )
 
 func DiscordAddURL(clientID string) string {
-	return fmt.Sprintf("https://discord.com/oauth2/authorize?client_id=%s&scope=bot&permissions=275146361856", clientID)
+	return fmt.Sprintf("https://discord.com/oauth2/authorize?client_id=%s&scope=bot&permissions=275146361920", clientID)
 }
 
 // the "ready" event from Discord.
@@ -37,14 +35,24 @@ func NewBot(token string) (*discordgo.Session, error) {
 }
 
 func StartBot(ctx context.Context, session *discordgo.Session, database *sql.DB, config Config) error {
+	if len(config.Guilds) == 0 {
+		slog.Warn("No guilds configured, commands are accepted from any server the bot is invited to")
+	}
+
 	session.AddHandler(ready)
 	session.AddHandler(messageCreate(ctx, database, config))
-	session.AddHandler(guildCreate)
+	session.AddHandler(interactionCreate(ctx, database, config))
+	session.AddHandler(messageReactionAdd(ctx, database, config))
+	session.AddHandler(guildCreate(config))
 
 	if errOpenDiscord := session.Open(); errOpenDiscord != nil {
 		return errors.Join(errOpenDiscord, errors.New("could not connect to discord"))
 	}
 
+	if errRegister := registerSlashCommands(session, config); errRegister != nil {
+		return errRegister
+	}
+
 	return nil
 }
 
@@ -117,41 +125,156 @@ func totalEntries(ctx context.Context, database *sql.DB) (string, error) {
 	return builder.String(), nil
 }
 
-func addEntry(ctx context.Context, database *sql.DB, sid steamid.SteamID, msg []string, author int64) (string, error) {
-	var attrs []string
-	if len(msg) == 2 {
-		attrs = append(attrs, "cheater")
-	} else {
-		for i := 2; i < len(msg); i++ {
-			if !slices.Contains(attrs, msg[i]) {
-				attrs = append(attrs, msg[i])
-			}
-		}
-	}
-
+// addEntry creates a new entry, using defaultAttr as its attribute when none are provided.
+func addEntry(ctx context.Context, database *sql.DB, config Config, sid steamid.SteamID, attributes []string,
+	defaultAttr string, guildID string, author int64,
+) (string, error) {
 	player := Player{
-		Attributes: attrs,
+		Attributes: attributes,
 		LastSeen: LastSeen{
 			Time: time.Now().Unix(),
 		},
 		SteamID: sid,
-		Proof:   []string{},
+		Proof:   Proof{},
+		GuildID: config.entryGuild(guildID),
 	}
 
-	if err := AddPlayer(ctx, database, player, author); err != nil {
-		if errors.Is(err, ErrDuplicate) {
+	added, errAdd := CreateEntry(ctx, database, config, player, defaultAttr, author)
+	if errAdd != nil {
+		if errors.Is(errAdd, ErrDuplicate) {
+			if _, errDeleted := getDeletedPlayer(ctx, database, sid); errDeleted == nil {
+				return "", fmt.Errorf("steam id was previously deleted, use !restore to bring it back: %s", sid.String())
+			}
+
 			return "", fmt.Errorf("duplicate steam id: %s", sid.String())
 		}
 
-		slog.Error("Failed to add player", slog.String("error", err.Error()))
+		return "", errAdd
+	}
 
-		return "", err
+	if !added.Confirmed {
+		return fmt.Sprintf("Added new entry, awaiting confirmation (1/%d): %s", config.RequiredConfirmations, sid.String()), nil
 	}
 
 	return fmt.Sprintf("Added new entry successfully: %s", sid.String()), nil
 }
 
-func checkEntry(ctx context.Context, database *sql.DB, sid steamid.SteamID) (string, error) {
+// CreateEntry validates and adds a new entry, using defaultAttr as its attribute when none are provided.
+// When confirmations are required, the entry starts unconfirmed with the author as the first confirmation.
+// Entries without a discord author, such as those added over the api, start without any confirmations.
+func CreateEntry(ctx context.Context, database *sql.DB, config Config, player Player, defaultAttr string, author int64) (Player, error) {
+	attrs, errAttrs := NormalizeAttributes(config.KnownAttributes, player.Attributes)
+	if errAttrs != nil {
+		return Player{}, errAttrs
+	}
+
+	if len(attrs) == 0 {
+		attrs = append(attrs, defaultAttr)
+	}
+
+	player.Attributes = attrs
+	player.Author = author
+	player.Confirmed = !config.confirmationsRequired()
+
+	var added Player
+
+	errAdd := withTx(ctx, database, func(tx *sql.Tx) error {
+		if err := addPlayer(ctx, tx, player, author, auditAdd); err != nil {
+			return err
+		}
+
+		if !player.Confirmed && author > 0 {
+			// The author counts as the first confirmation.
+			if err := addConfirmation(ctx, tx, player.SteamID, author); err != nil {
+				return err
+			}
+		}
+
+		var errAdded error
+		added, errAdded = getPlayer(ctx, tx, player.SteamID)
+
+		return errAdded
+	})
+
+	if errAdd != nil {
+		if !errors.Is(errAdd, ErrDuplicate) {
+			slog.Error("Failed to add player", slog.String("error", errAdd.Error()))
+		}
+
+		return Player{}, errAdd
+	}
+
+	return added, nil
+}
+
+type attributeEdit int
+
+const (
+	attributeSet attributeEdit = iota
+	attributeAdd
+	attributeRemove
+)
+
+// editAttributes changes the attributes of an existing entry, keeping all other fields intact.
+func editAttributes(ctx context.Context, database *sql.DB, config Config, sid steamid.SteamID, edit attributeEdit,
+	attributes []string, author int64,
+) (string, error) {
+	attrs, errAttrs := NormalizeAttributes(config.KnownAttributes, strings.Fields(strings.Join(attributes, " ")))
+	if errAttrs != nil {
+		return "", errAttrs
+	}
+
+	if len(attrs) == 0 {
+		return "", errors.New("no attributes provided")
+	}
+
+	player, errPlayer := getPlayer(ctx, database, sid)
+	if errPlayer != nil {
+		if errors.Is(errPlayer, ErrNotFound) {
+			return "", fmt.Errorf("steam id does not exist in database: %s", sid.String())
+		}
+
+		return "", errPlayer
+	}
+
+	var updated []string
+
+	switch edit {
+	case attributeSet:
+		updated = attrs
+	case attributeAdd:
+		updated = slices.Clone(player.Attributes)
+		for _, attr := range attrs {
+			if !slices.Contains(updated, attr) {
+				updated = append(updated, attr)
+			}
+		}
+	case attributeRemove:
+		for _, attr := range player.Attributes {
+			if !slices.Contains(attrs, attr) {
+				updated = append(updated, attr)
+			}
+		}
+	}
+
+	if len(updated) == 0 {
+		return "", errors.New("entry must keep at least one attribute, use !del to remove it")
+	}
+
+	if slices.Equal(updated, player.Attributes) {
+		return "", fmt.Errorf("attributes unchanged: %s", strings.Join(player.Attributes, ", "))
+	}
+
+	player.Attributes = updated
+
+	if errUpdate := updatePlayer(ctx, database, player, author); errUpdate != nil {
+		return "", errors.Join(errUpdate, errors.New("could not update player entry"))
+	}
+
+	return fmt.Sprintf("Updated attributes for %s: %s", sid.String(), strings.Join(updated, ", ")), nil
+}
+
+func checkEntry(ctx context.Context, database *sql.DB, config Config, sid steamid.SteamID) (string, error) {
 	player, errPlayer := getPlayer(ctx, database, sid)
 	if errPlayer != nil {
 		if errors.Is(errPlayer, ErrNotFound) {
@@ -162,24 +285,102 @@ func checkEntry(ctx context.Context, database *sql.DB, sid steamid.SteamID) (str
 	}
 
 	var builder strings.Builder
-	builder.WriteString(fmt.Sprintf("\n:skull_crossbones: **%s is a confirmed baddie** :skull_crossbones:\n", player.LastSeen.PlayerName))
+	if player.Confirmed {
+		builder.WriteString(fmt.Sprintf("\n:skull_crossbones: **%s is a confirmed baddie** :skull_crossbones:\n", player.LastSeen.PlayerName))
+	} else {
+		// Unconfirmed entries are not exported, so they are not presented as confirmed.
+		count, errCount := countConfirmations(ctx, database, sid)
+		if errCount != nil {
+			return "", errCount
+		}
+		builder.WriteString(fmt.Sprintf("\n:hourglass: **%s is awaiting confirmation (%d/%d)** :hourglass:\n",
+			player.LastSeen.PlayerName, count, config.RequiredConfirmations))
+	}
 	builder.WriteString(fmt.Sprintf("**Attributes:** %s\n", strings.Join(player.Attributes, ", ")))
 	for idx, proof := range player.Proof {
-		if strings.HasPrefix(proof, "http") {
-			builder.WriteString(fmt.Sprintf("**Proof #%d:** <%s>\n", idx, proof))
+		if strings.HasPrefix(proof.Value, "http") {
+			builder.WriteString(fmt.Sprintf("**Proof #%d:** <%s>", idx, proof.Value))
 		} else {
-			builder.WriteString(fmt.Sprintf("**Proof #%d:** %s\n", idx, proof))
+			builder.WriteString(fmt.Sprintf("**Proof #%d:** %s", idx, proof.Value))
 		}
+		if proof.Note != "" {
+			builder.WriteString(fmt.Sprintf(" - %s", proof.Note))
+		}
+		builder.WriteString("\n")
 	}
 	builder.WriteString(fmt.Sprintf("**Added on:** %s\n", player.CreatedOn.String()))
 	if player.Author > 0 {
 		builder.WriteString(fmt.Sprintf("**Author:** <@%d>\n", player.Author))
 	}
+	if guild, found := config.guild(player.GuildID); found && guild.Name != "" {
+		builder.WriteString(fmt.Sprintf("**Server:** %s\n", guild.Name))
+	} else if player.GuildID != "" {
+		builder.WriteString(fmt.Sprintf("**Server:** %s\n", player.GuildID))
+	}
+	if player.Source != "" {
+		builder.WriteString(fmt.Sprintf("**Upstream:** %s\n", player.Source))
+	}
+	if player.ClaimedBy > 0 {
+		builder.WriteString(fmt.Sprintf("**Claimed by:** <@%d>\n", player.ClaimedBy))
+	}
+	if player.Steam != nil {
+		if player.Steam.PersonaName != "" {
+			builder.WriteString(fmt.Sprintf("**Steam name:** %s\n", player.Steam.PersonaName))
+		}
+		builder.WriteString(fmt.Sprintf("**Visibility:** %s\n", player.Steam.Visibility))
+		if player.Steam.TimeCreated > 0 {
+			builder.WriteString(fmt.Sprintf("**Account created:** %s\n", time.Unix(player.Steam.TimeCreated, 0).UTC().Format(time.DateOnly)))
+		}
+		builder.WriteString(fmt.Sprintf("**VAC bans:** %d **Game bans:** %d\n", player.Steam.VACBans, player.Steam.GameBans))
+		if player.Steam.Avatar != "" {
+			builder.WriteString(fmt.Sprintf("**Avatar:** <%s>\n", player.Steam.Avatar))
+		}
+	}
 	builder.WriteString(fmt.Sprintf("**Profile:** <https://steamcommunity.com/profiles/%s>", sid.String()))
 
 	return builder.String(), nil
 }
 
+// historyLimit is the maximum number of audit entries shown by the history command.
+const historyLimit = 15
+
+func playerHistory(ctx context.Context, database *sql.DB, sid steamid.SteamID) (string, error) {
+	entries, errEntries := getAuditLog(ctx, database, sid, historyLimit)
+	if errEntries != nil {
+		return "", errEntries
+	}
+
+	if len(entries) == 0 {
+		return "", fmt.Errorf("no history exists for steam id: %s", sid.String())
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("**History for:** %s\n", sid.String()))
+	for _, entry := range entries {
+		builder.WriteString(fmt.Sprintf("`%s` **%s**", entry.CreatedOn.Format(time.DateTime), entry.Action))
+		if entry.Author > 0 {
+			builder.WriteString(fmt.Sprintf(" by <@%d>", entry.Author))
+		} else if entry.AuthorLabel != "" {
+			builder.WriteString(fmt.Sprintf(" by %s", entry.AuthorLabel))
+		}
+
+		switch {
+		case entry.Before != nil && entry.After != nil:
+			builder.WriteString(fmt.Sprintf(" attributes: %s -> %s, proof: %d -> %d",
+				strings.Join(entry.Before.Attributes, ", "), strings.Join(entry.After.Attributes, ", "),
+				len(entry.Before.Proof), len(entry.After.Proof)))
+		case entry.After != nil:
+			builder.WriteString(fmt.Sprintf(" attributes: %s", strings.Join(entry.After.Attributes, ", ")))
+		case entry.Before != nil:
+			builder.WriteString(fmt.Sprintf(" attributes: %s", strings.Join(entry.Before.Attributes, ", ")))
+		}
+
+		builder.WriteString("\n")
+	}
+
+	return builder.String(), nil
+}
+
 func getSteamid(sid steamid.SteamID) string {
 	var builder strings.Builder
 	builder.WriteString("```")
@@ -193,106 +394,202 @@ func getSteamid(sid steamid.SteamID) string {
 	return builder.String()
 }
 
-func importJSON(ctx context.Context, database *sql.DB, message *discordgo.MessageCreate) (string, error) {
-	if len(message.Attachments) == 0 {
-		return "", errors.New("must attach json file to import")
+func deleteEntry(ctx context.Context, database *sql.DB, sid steamid.SteamID, author int64) (string, error) {
+	_, errPlayer := getPlayer(ctx, database, sid)
+	if errPlayer != nil {
+		return "", fmt.Errorf("steam id does not exist in database: %s", sid.String())
 	}
 
-	client := &http.Client{}
-
-	importCtx, cancel := context.WithTimeout(ctx, time.Second*30)
-	defer cancel()
-
-	added := 0
-	known, errKnown := getPlayers(importCtx, database)
-	if errKnown != nil {
-		return "", errors.Join(errKnown, errors.New("failed to load existing entries for comparison"))
+	if err := DropPlayer(ctx, database, sid, author); err != nil {
+		return "", fmt.Errorf("error dropping player: %w", err)
 	}
 
-	author, errAuthor := strconv.ParseInt(message.Author.ID, 10, 64)
-	if errAuthor != nil {
-		return "", errors.New("failed to get discord author id")
-	}
+	return fmt.Sprintf("Dropped entry successfully: %s", sid.String()), nil
+}
 
-	for _, attach := range message.Attachments {
-		newCount, errLoad := loadAttachment(importCtx, client, database, attach.URL, known, author)
-		if errLoad != nil {
-			return "", errLoad
+func restoreEntry(ctx context.Context, database *sql.DB, sid steamid.SteamID, author int64) (string, error) {
+	if err := restorePlayer(ctx, database, sid, author); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return "", fmt.Errorf("no deleted entry exists for steam id: %s", sid.String())
 		}
 
-		added += newCount
+		return "", fmt.Errorf("error restoring player: %w", err)
 	}
 
-	return fmt.Sprintf("Loaded %d new players", added), nil
+	return fmt.Sprintf("Restored entry successfully: %s", sid.String()), nil
 }
 
-func loadAttachment(ctx context.Context, client *http.Client, database *sql.DB, url string, known []Player, author int64) (int, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return 0, errors.Join(err, errors.New("failed to setup http request"))
-	}
+func trimInputString(value string) string {
+	return strings.TrimSpace(regexp.MustCompile(`\s+`).ReplaceAllString(value, " "))
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, errors.Join(err, errors.New("failed to download file"))
-	}
+// commandRequest is the transport independent form of a bot command. It is built from either
+// a legacy prefixed text message or a slash command interaction.
+type commandRequest struct {
+	guildID     string
+	channelID   string
+	authorID    string
+	args        []string
+	attachments []*discordgo.MessageAttachment
+	// content is the unmodified text of a message, keeping the line breaks removed from args.
+	content string
+}
 
-	defer func() {
-		if errClose := resp.Body.Close(); errClose != nil {
-			slog.Error("failed to close body", slog.String("error", errClose.Error()))
-		}
-	}()
+var errUnknownCommand = errors.New("unknown command")
+
+// textCommands take free text as their arguments rather than a steam id.
+var textCommands = []string{"lobby", "import"}
+
+// bulkCommands accept several steam ids at once, see bulkEntries.
+var bulkCommands = []string{"add", "del"}
+
+// commandMinArgs defines the known commands and the minimum amount of args, including the
+// command name itself, they require.
+var commandMinArgs = map[string]int{
+	"del":       2,
+	"check":     2,
+	"add":       2,
+	"steamid":   2,
+	"import":    1,
+	"count":     1,
+	"link":      1,
+	"addproof":  2,
+	"rmproof":   3,
+	"editproof": 4,
+	"moveproof": 4,
+	"history":   2,
+	"names":     2,
+	"lobby":     1,
+	"confirm":   2,
+	"claim":     2,
+	"report":    3,
+	"restore":   2,
+	"setattr":   3,
+	"addattr":   3,
+	"rmattr":    3,
+}
 
-	var playerList PlayerListRoot
-	if errDecode := json.NewDecoder(resp.Body).Decode(&playerList); errDecode != nil {
-		slog.Error("error decoding", slog.String("error", errDecode.Error()))
+func handleCommand(ctx context.Context, session *discordgo.Session, database *sql.DB, config Config, req commandRequest) (string, error) {
+	command := strings.ToLower(req.args[0])
 
-		return 0, errors.Join(errDecode, errors.New("failed to decode file"))
+	argCount, found := commandMinArgs[command]
+	if !found {
+		return "", errUnknownCommand
 	}
 
-	var toAdd []Player
-	for _, player := range playerList.Players {
-		found := false
-		for _, existing := range known {
-			if player.SteamID.Int64() == existing.SteamID.Int64() {
-				found = true
+	if len(req.args) < argCount {
+		return "", fmt.Errorf("command requires at least %d args", argCount)
+	}
 
-				break
-			}
-		}
-		if !found {
-			toAdd = append(toAdd, player)
-		}
+	if !config.GuildAllowed(req.guildID) {
+		return "", errors.New("this server is not authorized to use the bot")
 	}
 
-	added := 0
-	for _, p := range toAdd {
-		if errAdd := AddPlayer(ctx, database, p, author); errAdd != nil {
-			slog.Error("failed to add new entry", slog.String("error", errAdd.Error()))
+	allowedRoles, public := config.RolesForCommand(req.guildID, command)
+	if !public {
+		allowed, err := memberHasRole(session, req.guildID, req.authorID, allowedRoles)
+		if err != nil {
+			slog.Error("Failed to lookup role data", slog.String("error", err.Error()))
 
-			continue
+			return "", errors.New("failed to lookup role data")
 		}
-		added++
-	}
 
-	return added, nil
-}
+		if !allowed {
+			return "", errors.New("unauthorized")
+		}
+	}
 
-func deleteEntry(ctx context.Context, database *sql.DB, sid steamid.SteamID) (string, error) {
-	_, errPlayer := getPlayer(ctx, database, sid)
-	if errPlayer != nil {
-		return "", fmt.Errorf("steam id does not exist in database: %s", sid.String())
+	author, errAuthor := strconv.ParseInt(req.authorID, 10, 64)
+	if errAuthor != nil {
+		return "", errors.New("failed to get discord author id")
 	}
 
-	if err := dropPlayer(ctx, database, sid); err != nil {
-		return "", fmt.Errorf("error dropping player: %w", err)
+	if slices.Contains(bulkCommands, command) && len(req.args) > 1 {
+		// Commands given several steam ids resolve and apply them all at once.
+		if steamIDs, attributes := splitBulkArgs(config.KnownAttributes, req.args[1:]); len(steamIDs) > 1 {
+			results, response, errBulk := bulkEntries(ctx, database, config, command, steamIDs, attributes,
+				config.defaultAttribute(req.channelID), req.guildID, author)
+			if errBulk == nil && command == "add" && config.confirmationsRequired() {
+				for _, result := range results {
+					if result.status == bulkAdded {
+						announceUnconfirmed(ctx, session, database, config, req.channelID, result.steamID, author)
+					}
+				}
+			}
+
+			return response, errBulk
+		}
 	}
 
-	return fmt.Sprintf("Dropped entry successfully: %s", sid.String()), nil
-}
+	var sid steamid.SteamID
+	if len(req.args) > 1 && !slices.Contains(textCommands, command) {
+		resolveCtx, cancel := context.WithTimeout(ctx, time.Second*10)
+		defer cancel()
 
-func trimInputString(value string) string {
-	return strings.TrimSpace(regexp.MustCompile(`\s+`).ReplaceAllString(value, " "))
+		idStr := req.args[1]
+		userSid, errSid := steamid.Resolve(resolveCtx, idStr)
+		if errSid != nil {
+			return "", fmt.Errorf("cannot resolve steam id: %s", idStr)
+		} else if !userSid.Valid() {
+			return "", fmt.Errorf("invalid SteamID: %s", idStr)
+		}
+
+		sid = userSid
+	}
+
+	switch command {
+	case "del":
+		return deleteEntry(ctx, database, sid, author)
+	case "restore":
+		return restoreEntry(ctx, database, sid, author)
+	case "link":
+		return getLink(config, req.channelID)
+	case "check":
+		return checkEntry(ctx, database, config, sid)
+	case "history":
+		return playerHistory(ctx, database, sid)
+	case "names":
+		return playerNames(ctx, database, sid)
+	case "lobby":
+		return lobbyEntry(ctx, session, database, config, req, author)
+	case "addproof":
+		return addProof(ctx, database, config, sid, trimInputString(strings.Join(req.args[2:], " ")), req.attachments, author)
+	case "rmproof":
+		return removeProof(ctx, database, sid, req.args[2], author)
+	case "editproof":
+		return editProof(ctx, database, sid, req.args[2], trimInputString(strings.Join(req.args[3:], " ")), author)
+	case "moveproof":
+		return moveProof(ctx, database, sid, req.args[2], req.args[3], author)
+	case "add":
+		response, errAdd := addEntry(ctx, database, config, sid, req.args[2:],
+			config.defaultAttribute(req.channelID), req.guildID, author)
+		if errAdd == nil && config.confirmationsRequired() {
+			announceUnconfirmed(ctx, session, database, config, req.channelID, sid, author)
+		}
+
+		return response, errAdd
+	case "confirm":
+		return confirmEntry(ctx, database, config, sid, author)
+	case "claim":
+		return claimEntry(ctx, database, sid, author)
+	case "setattr":
+		return editAttributes(ctx, database, config, sid, attributeSet, req.args[2:], author)
+	case "addattr":
+		return editAttributes(ctx, database, config, sid, attributeAdd, req.args[2:], author)
+	case "rmattr":
+		return editAttributes(ctx, database, config, sid, attributeRemove, req.args[2:], author)
+	case "report":
+		return reportEntry(ctx, session, database, config, sid, req.args[2:],
+			config.defaultAttribute(req.channelID), author)
+	case "steamid":
+		return getSteamid(sid), nil
+	case "count":
+		return totalEntries(ctx, database)
+	case "import":
+		return importJSON(ctx, session, database, config, req, author)
+	}
+
+	return "", errUnknownCommand
 }
 
 func messageCreate(ctx context.Context, database *sql.DB, config Config) func(*discordgo.Session, *discordgo.MessageCreate) {
@@ -301,115 +598,149 @@ func messageCreate(ctx context.Context, database *sql.DB, config Config) func(*d
 		if message.Author.ID == session.State.User.ID {
 			return
 		}
+
 		msg := strings.Split(trimInputString(message.Content), " ")
-		command := strings.ToLower(msg[0])
-		minArgs := map[string]int{
-			"!del":      2,
-			"!check":    2,
-			"!add":      2,
-			"!steamid":  2,
-			"!import":   1,
-			"!count":    1,
-			"!link":     1,
-			"!addproof": 3,
-		}
-
-		argCount, found := minArgs[command]
-		if !found {
+		if !strings.HasPrefix(msg[0], "!") {
 			return
 		}
+		msg[0] = strings.TrimPrefix(msg[0], "!")
+
+		response, errCmd := handleCommand(ctx, session, database, config, commandRequest{
+			guildID:     message.GuildID,
+			channelID:   message.ChannelID,
+			authorID:    message.Author.ID,
+			args:        msg,
+			attachments: message.Attachments,
+			content:     message.Content,
+		})
+
+		if errCmd != nil {
+			if errors.Is(errCmd, errUnknownCommand) {
+				return
+			}
 
-		if len(msg) < argCount {
-			sendMsg(session, message, fmt.Sprintf("Command requires at least %d args", argCount))
+			sendMsg(session, message, errCmd.Error())
 
 			return
 		}
 
-		allowed, err := memberHasRole(session, message.GuildID, message.Author.ID, config.DiscordRoles)
-		if err != nil {
-			slog.Error("Failed to lookup role data", slog.String("error", err.Error()))
-			sendMsg(session, message, "Failed to lookup role data")
+		sendMsg(session, message, response)
+	}
+}
 
-			return
+// getLink returns the url of the list bound to the channel, or the url of every list otherwise.
+func getLink(config Config, channelID string) (string, error) {
+	if list, found := config.listForChannel(channelID); found {
+		link, errLink := config.ListURL(list.Name)
+		if errLink != nil {
+			return "", errLink
 		}
 
-		if !allowed && command != "!steamid" && command != "!count" {
-			sendMsg(session, message, "Unauthorized")
+		return fmt.Sprintf("<%s>", link), nil
+	}
+
+	link, errLink := config.UpdateURL()
+	if errLink != nil {
+		return "", errLink
+	}
 
-			return
+	if len(config.Lists) == 0 {
+		return fmt.Sprintf("<%s>", link), nil
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("**%s:** <%s>", config.ListTitle, link))
+
+	for _, list := range config.Lists {
+		listLink, errListLink := config.ListURL(list.Name)
+		if errListLink != nil {
+			return "", errListLink
 		}
 
-		var sid steamid.SteamID
-		if len(msg) > 1 {
-			resolveCtx, cancel := context.WithTimeout(ctx, time.Second*10)
-			defer cancel()
+		builder.WriteString(fmt.Sprintf("\n**%s:** <%s>", list.Title, listLink))
+	}
 
-			idStr := msg[1]
-			userSid, errSid := steamid.Resolve(resolveCtx, idStr)
-			if errSid != nil {
-				sendMsg(session, message, fmt.Sprintf("Cannot resolve steam id: %s", idStr))
+	return builder.String(), nil
+}
 
-				return
-			} else if !userSid.Valid() {
-				sendMsg(session, message, fmt.Sprintf("Invalid SteamID: %s", idStr))
+func addProof(ctx context.Context, database *sql.DB, config Config, sid steamid.SteamID, input string,
+	attachments []*discordgo.MessageAttachment, author int64,
+) (string, error) {
+	proof := parseProofInput(input)
+	if proof.Value == "" && len(attachments) == 0 {
+		return "", errors.New("empty proof value")
+	}
 
-				return
-			}
+	player, errPlayer := getPlayer(ctx, database, sid)
+	if errPlayer != nil {
+		return "", errPlayer
+	}
+
+	var newProof Proof
+	if proof.Value != "" {
+		newProof = append(newProof, proof)
+	}
 
-			sid = userSid
+	if len(attachments) > 0 {
+		// When only attachments are provided, the note applies to them instead.
+		note := proof.Note
+		if proof.Value != "" {
+			note = ""
 		}
 
-		var (
-			response string
-			cmdErr   error
-		)
+		attachmentProof, errAttachments := storeAttachmentProof(ctx, config, attachments, note)
+		if errAttachments != nil {
+			return "", errAttachments
+		}
 
-		switch strings.ToLower(msg[0]) {
-		case "!del":
-			response, cmdErr = deleteEntry(ctx, database, sid)
-		case "!link":
-			response, cmdErr = getLink(config)
-		case "!check":
-			response, cmdErr = checkEntry(ctx, database, sid)
-		case "!addproof":
-			response, cmdErr = addProof(ctx, database, sid, trimInputString(strings.Join(msg[2:], " ")))
-		case "!add":
-			author, errAuthor := strconv.ParseInt(message.Author.ID, 10, 64)
-			if errAuthor != nil {
-				cmdErr = errors.New("failed to get discord author id")
+		newProof = append(newProof, attachmentProof...)
+	}
 
-				break
-			}
-			response, cmdErr = addEntry(ctx, database, sid, msg, author)
-		case "!steamid":
-			response = getSteamid(sid)
-		case "!count":
-			response, cmdErr = totalEntries(ctx, database)
-		case "!import":
-			response, cmdErr = importJSON(ctx, database, message)
-		}
+	if errAppend := appendProof(ctx, database, player, newProof, author); errAppend != nil {
+		return "", errAppend
+	}
 
-		if cmdErr != nil {
-			sendMsg(session, message, cmdErr.Error())
+	if len(newProof) > 1 {
+		return fmt.Sprintf("Added %d proof entries successfully", len(newProof)), nil
+	}
 
-			return
-		}
+	return "Added proof entry successfully", nil
+}
 
-		sendMsg(session, message, response)
+// proofIndex parses a proof index, as shown by the check command, validating that it exists for the player.
+func proofIndex(player Player, value string) (int, error) {
+	index, errIndex := strconv.Atoi(value)
+	if errIndex != nil || index < 0 || index >= len(player.Proof) {
+		return 0, fmt.Errorf("invalid proof index: %s, must be between 0 and %d", value, len(player.Proof)-1)
 	}
+
+	return index, nil
 }
 
-func getLink(config Config) (string, error) {
-	link, errLink := config.UpdateURL()
-	if errLink != nil {
-		return "", errLink
+func removeProof(ctx context.Context, database *sql.DB, sid steamid.SteamID, indexValue string, author int64) (string, error) {
+	player, errPlayer := getPlayer(ctx, database, sid)
+	if errPlayer != nil {
+		return "", errPlayer
 	}
 
-	return fmt.Sprintf("<%s>", link), nil
+	index, errIndex := proofIndex(player, indexValue)
+	if errIndex != nil {
+		return "", errIndex
+	}
+
+	removed := player.Proof[index]
+	player.Proof = slices.Delete(player.Proof, index, index+1)
+
+	if errUpdate := updatePlayer(ctx, database, player, author); errUpdate != nil {
+		return "", errors.Join(errUpdate, errors.New("could not update player entry"))
+	}
+
+	return fmt.Sprintf("Removed proof entry #%d successfully: <%s>", index, removed.Value), nil
 }
 
-func addProof(ctx context.Context, database *sql.DB, sid steamid.SteamID, proof string) (string, error) {
-	if proof == "" {
+func editProof(ctx context.Context, database *sql.DB, sid steamid.SteamID, indexValue string, input string, author int64) (string, error) {
+	proof := parseProofInput(input)
+	if proof.Value == "" {
 		return "", errors.New("empty proof value")
 	}
 
@@ -417,16 +748,47 @@ func addProof(ctx context.Context, database *sql.DB, sid steamid.SteamID, proof
 	if errPlayer != nil {
 		return "", errPlayer
 	}
-	if slices.Contains(player.Proof, proof) {
-		return "", errors.New("duplicate proof provided")
+
+	index, errIndex := proofIndex(player, indexValue)
+	if errIndex != nil {
+		return "", errIndex
 	}
-	player.Proof = append(player.Proof, proof)
 
-	if errUpdate := updatePlayer(ctx, database, player); errUpdate != nil {
+	player.Proof[index].Value = proof.Value
+	player.Proof[index].Note = proof.Note
+	player.Proof[index].Kind = proof.Kind
+
+	if errUpdate := updatePlayer(ctx, database, player, author); errUpdate != nil {
 		return "", errors.Join(errUpdate, errors.New("could not update player entry"))
 	}
 
-	return "Added proof entry successfully", nil
+	return fmt.Sprintf("Updated proof entry #%d successfully", index), nil
+}
+
+func moveProof(ctx context.Context, database *sql.DB, sid steamid.SteamID, fromValue string, toValue string, author int64) (string, error) {
+	player, errPlayer := getPlayer(ctx, database, sid)
+	if errPlayer != nil {
+		return "", errPlayer
+	}
+
+	from, errFrom := proofIndex(player, fromValue)
+	if errFrom != nil {
+		return "", errFrom
+	}
+
+	dest, errTo := proofIndex(player, toValue)
+	if errTo != nil {
+		return "", errTo
+	}
+
+	proof := player.Proof[from]
+	player.Proof = slices.Insert(slices.Delete(player.Proof, from, from+1), dest, proof)
+
+	if errUpdate := updatePlayer(ctx, database, player, author); errUpdate != nil {
+		return "", errors.Join(errUpdate, errors.New("could not update player entry"))
+	}
+
+	return fmt.Sprintf("Moved proof entry #%d to #%d successfully", from, dest), nil
 }
 
 func sendMsg(s *discordgo.Session, m *discordgo.MessageCreate, msg string) {
@@ -435,14 +797,33 @@ func sendMsg(s *discordgo.Session, m *discordgo.MessageCreate, msg string) {
 	}
 }
 
-// This function will be called every time a new guild is joined.
-func guildCreate(_ *discordgo.Session, event *discordgo.GuildCreate) {
-	if event.Guild.Unavailable {
-		return
+// guildCreate leaves any guild that is not authorized to use the bot as soon as it is joined, or when
+// connecting to discord if it was joined previously.
+func guildCreate(config Config) func(*discordgo.Session, *discordgo.GuildCreate) {
+	return func(session *discordgo.Session, event *discordgo.GuildCreate) {
+		if event.Guild.Unavailable {
+			return
+		}
+
+		if !config.GuildAllowed(event.Guild.ID) {
+			slog.Warn("Leaving unauthorized server", slog.String("guild", event.Guild.Name),
+				slog.String("guild_id", event.Guild.ID))
+
+			if errLeave := session.GuildLeave(event.Guild.ID); errLeave != nil {
+				slog.Error("Failed to leave server", slog.String("error", errLeave.Error()))
+			}
+
+			return
+		}
+
+		logGuild(event.Guild)
 	}
-	for _, channel := range event.Guild.Channels {
-		if channel.ID == event.Guild.ID {
-			slog.Info("Connected to server", slog.String("guild", event.Guild.Name))
+}
+
+func logGuild(guild *discordgo.Guild) {
+	for _, channel := range guild.Channels {
+		if channel.ID == guild.ID {
+			slog.Info("Connected to server", slog.String("guild", guild.Name))
 
 			return
 		}
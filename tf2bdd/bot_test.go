@@ -0,0 +1,504 @@
+package tf2bdd_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/leighmacdonald/steamid/v4/steamid"
+	"github.com/leighmacdonald/tf2bdd/tf2bdd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlashCommandsRequiredOptionsFirst(t *testing.T) {
+	testConfig := tf2bdd.Config{KnownAttributes: []string{"cheater", "suspicious"}}
+
+	for _, command := range tf2bdd.SlashCommands(testConfig) {
+		optional := false
+
+		for _, option := range command.Options {
+			if !option.Required {
+				optional = true
+
+				continue
+			}
+
+			require.False(t, optional, "required option %s of %s follows an optional option", option.Name, command.Name)
+		}
+	}
+}
+
+func TestSlashCommandArgs(t *testing.T) {
+	testConfig := tf2bdd.Config{KnownAttributes: []string{"cheater", "suspicious"}}
+
+	interaction := func(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+		return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			Type:   discordgo.InteractionApplicationCommand,
+			Member: &discordgo.Member{User: &discordgo.User{ID: "1234"}},
+			Data:   discordgo.ApplicationCommandInteractionData{Name: name, Options: options},
+		}}
+	}
+	option := func(name string, value string) *discordgo.ApplicationCommandInteractionDataOption {
+		return &discordgo.ApplicationCommandInteractionDataOption{
+			Name:  name,
+			Type:  discordgo.ApplicationCommandOptionString,
+			Value: value,
+		}
+	}
+
+	// Options are converted in the order of the text command args, regardless of their declared order.
+	require.Equal(t, []string{"report", "76561197960287930", "suspicious", "https://example.com", "|", "note"},
+		tf2bdd.SlashCommandArgs(interaction("report", option("note", "note"), option("proof", "https://example.com"),
+			option("steamid", "76561197960287930"), option("attribute", "suspicious")), testConfig))
+	require.Equal(t, []string{"report", "76561197960287930", "https://example.com"},
+		tf2bdd.SlashCommandArgs(interaction("report", option("steamid", "76561197960287930"),
+			option("proof", "https://example.com")), testConfig))
+	require.Equal(t, []string{"add", "76561197960287930 76561197960265729", "cheater"},
+		tf2bdd.SlashCommandArgs(interaction("add", option("steamid", "76561197960287930 76561197960265729"),
+			option("attribute", "cheater")), testConfig))
+	require.Equal(t, []string{"add", "76561197960287930", "cheater", "suspicious"},
+		tf2bdd.SlashCommandArgs(interaction("add", option("attribute2", "suspicious"),
+			option("steamid", "76561197960287930"), option("attribute", "cheater")), testConfig))
+	require.Equal(t, []string{"report", "76561197960287930", "cheater", "suspicious", "https://example.com"},
+		tf2bdd.SlashCommandArgs(interaction("report", option("steamid", "76561197960287930"),
+			option("proof", "https://example.com"), option("attribute", "cheater"), option("attribute3", "suspicious")),
+			testConfig))
+}
+
+func TestConfirmEntry(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		KnownAttributes:       []string{"cheater", "suspicious"},
+		RequiredConfirmations: 3,
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	sid := steamid.New(76561197960287930)
+
+	response, errAdd := tf2bdd.AddEntry(ctx, database, testConfig, sid, nil, "cheater", "", 1)
+	require.NoError(t, errAdd)
+	require.Contains(t, response, "awaiting confirmation (1/3)")
+
+	check, errCheck := tf2bdd.CheckEntry(ctx, database, testConfig, sid)
+	require.NoError(t, errCheck)
+	require.Contains(t, check, "awaiting confirmation (1/3)")
+	require.NotContains(t, check, "confirmed baddie")
+
+	// The author already counts as the first confirmation.
+	_, errDuplicate := tf2bdd.ConfirmEntry(ctx, database, testConfig, sid, 1)
+	require.ErrorContains(t, errDuplicate, "already confirmed")
+
+	response, errConfirm := tf2bdd.ConfirmEntry(ctx, database, testConfig, sid, 2)
+	require.NoError(t, errConfirm)
+	require.Contains(t, response, "(2/3)")
+
+	_, errDuplicate = tf2bdd.ConfirmEntry(ctx, database, testConfig, sid, 2)
+	require.ErrorContains(t, errDuplicate, "already confirmed")
+
+	player, errPlayer := tf2bdd.GetPlayer(ctx, database, sid)
+	require.NoError(t, errPlayer)
+	require.False(t, player.Confirmed)
+
+	response, errConfirm = tf2bdd.ConfirmEntry(ctx, database, testConfig, sid, 3)
+	require.NoError(t, errConfirm)
+	require.Contains(t, response, "confirmed and published")
+
+	player, errPlayer = tf2bdd.GetPlayer(ctx, database, sid)
+	require.NoError(t, errPlayer)
+	require.True(t, player.Confirmed)
+
+	check, errCheck = tf2bdd.CheckEntry(ctx, database, testConfig, sid)
+	require.NoError(t, errCheck)
+	require.Contains(t, check, "confirmed baddie")
+
+	_, errConfirmed := tf2bdd.ConfirmEntry(ctx, database, testConfig, sid, 4)
+	require.ErrorContains(t, errConfirmed, "is already confirmed")
+
+	_, errMissing := tf2bdd.ConfirmEntry(ctx, database, testConfig, steamid.New(76561197960265729), 2)
+	require.ErrorContains(t, errMissing, "does not exist")
+}
+
+func TestConfirmAPIEntry(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		KnownAttributes:       []string{"cheater", "suspicious"},
+		RequiredConfirmations: 2,
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	_, writeToken, errWrite := tf2bdd.CreateAPIToken(ctx, database, tf2bdd.ScopeWrite, "plugin")
+	require.NoError(t, errWrite)
+
+	router := tf2bdd.CreateRouter(database, testConfig)
+
+	for _, sid := range []string{"76561197960287930", "76561197960265729"} {
+		req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/players",
+			strings.NewReader(`{"steamid": "`+sid+`", "attributes": ["cheater"]}`))
+		require.NoError(t, errReq)
+		req.Header.Set("Authorization", "Bearer "+writeToken)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusCreated, recorder.Code)
+	}
+
+	// Entries added over the api have no author to count as their first confirmation.
+	for _, sid := range []int64{76561197960287930, 76561197960265729} {
+		response, errConfirm := tf2bdd.ConfirmEntry(ctx, database, testConfig, steamid.New(sid), 1)
+		require.NoError(t, errConfirm)
+		require.Contains(t, response, "(1/2)")
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	status := `hostname: Valve Matchmaking Server (Virginia srcds1001-iad1 #86)
+version : 8835751/24 8835751 secure
+steamid : [G:1:1234567] (85568392921234567)
+map     : pl_upward at: 0 x, 0 y, 0 z
+players : 3 humans, 0 bots (32 max)
+# userid name                uniqueid            connected ping loss state  adr
+#    392 "gaben"             [U:1:22202]         05:12       67    0 active
+#    398 "name "with" quotes" [U:1:1234]     1:02:33    80    0 active
+#    401 "gaben"             [U:1:22202]         05:12       67    0 active
+#    402 ""                  [U:1:5678]          00:10      100    0 spawning
+`
+
+	players := tf2bdd.ParseStatus(status)
+	require.Len(t, players, 3)
+	require.Equal(t, steamid.New("[U:1:22202]"), players[0].SteamID)
+	require.Equal(t, "gaben", players[0].Name)
+	require.Equal(t, steamid.New("[U:1:1234]"), players[1].SteamID)
+	require.Equal(t, `name "with" quotes`, players[1].Name)
+	require.Equal(t, steamid.New("[U:1:5678]"), players[2].SteamID)
+	require.Empty(t, players[2].Name)
+
+	// Text commands join the lines of pasted output together.
+	joined := tf2bdd.ParseStatus(`#    392 "gaben" [U:1:22202] 05:12 67 0 active #    398 "robin" [U:1:1234] 1:02:33 80 0 active`)
+	require.Len(t, joined, 2)
+	require.Equal(t, "gaben", joined[0].Name)
+	require.Equal(t, "robin", joined[1].Name)
+	require.Equal(t, steamid.New("[U:1:1234]"), joined[1].SteamID)
+
+	// Lobby debug output does not include names.
+	lobby := tf2bdd.ParseStatus("  Member[0] [U:1:22202]  team = TF_GC_TEAM_DEFENDERS  type = MATCH_PLAYER\n" +
+		"  Pending[0] [U:1:1234]  team = TF_GC_TEAM_INVADERS  type = MATCH_PLAYER")
+	require.Len(t, lobby, 2)
+	require.Empty(t, lobby[0].Name)
+
+	require.Empty(t, tf2bdd.ParseStatus("hostname: test\nplayers : 0 humans, 0 bots (24 max)"))
+}
+
+func TestReadLobbyAttachments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/status.txt" {
+			writer.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(writer, "<Error><Code>AccessDenied</Code></Error>")
+
+			return
+		}
+
+		_, _ = io.WriteString(writer, `#    392 "gaben" [U:1:22202] 05:12 67 0 active`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	status, errRead := tf2bdd.ReadLobbyAttachments(ctx, []*discordgo.MessageAttachment{{URL: server.URL + "/status.txt"}})
+	require.NoError(t, errRead)
+	require.Len(t, tf2bdd.ParseStatus(status), 1)
+
+	_, errDenied := tf2bdd.ReadLobbyAttachments(ctx, []*discordgo.MessageAttachment{{URL: server.URL + "/expired.txt"}})
+	require.Error(t, errDenied)
+}
+
+func TestSplitBulkArgs(t *testing.T) {
+	known := []string{"cheater", "suspicious", "racist"}
+
+	// Arguments resembling an attribute are attributes, even when they could be resolved as a vanity name.
+	steamIDs, attributes := tf2bdd.SplitBulkArgs(known, []string{"cheater", "cheat", "racis\n76561197960287930", "Racist", "suspicious"})
+	require.Equal(t, []string{"cheater", "76561197960287930"}, steamIDs)
+	require.Equal(t, []string{"cheat", "racis", "Racist", "suspicious"}, attributes)
+
+	steamIDs, attributes = tf2bdd.SplitBulkArgs(known, []string{"76561197960287930"})
+	require.Equal(t, []string{"76561197960287930"}, steamIDs)
+	require.Empty(t, attributes)
+}
+
+func TestBulkEntries(t *testing.T) {
+	testConfig := tf2bdd.Config{KnownAttributes: []string{"cheater", "suspicious"}}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	response, errAdd := tf2bdd.BulkEntries(ctx, database, testConfig, "add",
+		[]string{"76561197960287930", "[U:1:1234]", "76561197960287930", "not-a-steam-id!"}, []string{"suspicious"}, 1)
+	require.NoError(t, errAdd)
+	require.Contains(t, response, "**Added 2** as `suspicious`, duplicate 1, unresolvable 1")
+
+	for _, sid := range []steamid.SteamID{steamid.New(76561197960287930), steamid.New("[U:1:1234]")} {
+		player, errPlayer := tf2bdd.GetPlayer(ctx, database, sid)
+		require.NoError(t, errPlayer)
+		require.Equal(t, []string{"suspicious"}, player.Attributes)
+	}
+
+	response, errAdd = tf2bdd.BulkEntries(ctx, database, testConfig, "add",
+		[]string{"76561197960287930", "76561197960265729"}, nil, 1)
+	require.NoError(t, errAdd)
+	require.Contains(t, response, "**Added 1** as `cheater`, duplicate 1")
+
+	// Arguments resembling an attribute are reported as a mistyped attribute, before anything is resolved.
+	steamIDs, attributes := tf2bdd.SplitBulkArgs(testConfig.KnownAttributes,
+		[]string{"76561197960265730", "76561197960265731", "chaeter"})
+	_, errTypo := tf2bdd.BulkEntries(ctx, database, testConfig, "add", steamIDs, attributes, 1)
+	require.ErrorIs(t, errTypo, tf2bdd.ErrUnknownAttribute)
+	require.ErrorContains(t, errTypo, "did you mean: cheater")
+
+	for _, sid := range []steamid.SteamID{steamid.New(76561197960265730), steamid.New(76561197960265731)} {
+		_, errPlayer := tf2bdd.GetPlayer(ctx, database, sid)
+		require.ErrorIs(t, errPlayer, tf2bdd.ErrNotFound)
+	}
+
+	_, errDelAttrs := tf2bdd.BulkEntries(ctx, database, testConfig, "del",
+		[]string{"76561197960287930", "[U:1:1234]"}, []string{"cheater"}, 1)
+	require.Error(t, errDelAttrs)
+
+	response, errDel := tf2bdd.BulkEntries(ctx, database, testConfig, "del",
+		[]string{"76561197960287930", "[U:1:1234]", "76561197960265730"}, nil, 1)
+	require.NoError(t, errDel)
+	require.Contains(t, response, "**Deleted 2**, not found 1, unresolvable 0")
+
+	_, errDeleted := tf2bdd.GetPlayer(ctx, database, steamid.New(76561197960287930))
+	require.ErrorIs(t, errDeleted, tf2bdd.ErrNotFound)
+}
+
+func TestEditAttributes(t *testing.T) {
+	testConfig := tf2bdd.Config{KnownAttributes: []string{"cheater", "suspicious", "racist"}}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	sid := steamid.New(76561197960287930)
+	require.NoError(t, tf2bdd.AddPlayer(ctx, database, tf2bdd.Player{SteamID: sid, Attributes: []string{"cheater"}}, 0))
+
+	attributes := func() []string {
+		player, errPlayer := tf2bdd.GetPlayer(ctx, database, sid)
+		require.NoError(t, errPlayer)
+
+		return player.Attributes
+	}
+
+	_, errUnknown := tf2bdd.EditAttributes(ctx, database, testConfig, sid, tf2bdd.AttributeSet, []string{"chaeter"}, 1)
+	require.ErrorIs(t, errUnknown, tf2bdd.ErrUnknownAttribute)
+
+	_, errEmpty := tf2bdd.EditAttributes(ctx, database, testConfig, sid, tf2bdd.AttributeAdd, nil, 1)
+	require.Error(t, errEmpty)
+
+	_, errMissing := tf2bdd.EditAttributes(ctx, database, testConfig, steamid.New(76561197960265729),
+		tf2bdd.AttributeAdd, []string{"racist"}, 1)
+	require.ErrorContains(t, errMissing, "does not exist")
+
+	// Attributes that are already set, or repeated, are only added once.
+	_, errAdd := tf2bdd.EditAttributes(ctx, database, testConfig, sid, tf2bdd.AttributeAdd,
+		[]string{"Cheater racist", "racist"}, 1)
+	require.NoError(t, errAdd)
+	require.ElementsMatch(t, []string{"cheater", "racist"}, attributes())
+
+	_, errUnchanged := tf2bdd.EditAttributes(ctx, database, testConfig, sid, tf2bdd.AttributeAdd, []string{"racist"}, 1)
+	require.ErrorContains(t, errUnchanged, "unchanged")
+
+	_, errRemove := tf2bdd.EditAttributes(ctx, database, testConfig, sid, tf2bdd.AttributeRemove, []string{"cheater"}, 1)
+	require.NoError(t, errRemove)
+	require.Equal(t, []string{"racist"}, attributes())
+
+	// The last attribute cannot be removed, the entry must be deleted instead.
+	_, errLast := tf2bdd.EditAttributes(ctx, database, testConfig, sid, tf2bdd.AttributeRemove, []string{"racist"}, 1)
+	require.ErrorContains(t, errLast, "at least one attribute")
+	require.Equal(t, []string{"racist"}, attributes())
+
+	_, errSet := tf2bdd.EditAttributes(ctx, database, testConfig, sid, tf2bdd.AttributeSet,
+		[]string{"suspicious", "cheater", "suspicious"}, 1)
+	require.NoError(t, errSet)
+	require.ElementsMatch(t, []string{"suspicious", "cheater"}, attributes())
+}
+
+func TestEditProof(t *testing.T) {
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	sid := steamid.New(76561197960287930)
+	require.NoError(t, tf2bdd.AddPlayer(ctx, database, tf2bdd.Player{
+		SteamID:    sid,
+		Attributes: []string{"cheater"},
+		Proof:      tf2bdd.Proof{{Value: "first"}, {Value: "second"}, {Value: "third"}},
+	}, 0))
+
+	proof := func() []string {
+		player, errPlayer := tf2bdd.GetPlayer(ctx, database, sid)
+		require.NoError(t, errPlayer)
+
+		var values []string
+		for _, entry := range player.Proof {
+			values = append(values, entry.Value)
+		}
+
+		return values
+	}
+
+	for _, index := range []string{"3", "-1", "one"} {
+		_, errRemove := tf2bdd.RemoveProof(ctx, database, sid, index, 1)
+		require.ErrorContains(t, errRemove, "invalid proof index")
+
+		_, errEdit := tf2bdd.EditProof(ctx, database, sid, index, "value", 1)
+		require.ErrorContains(t, errEdit, "invalid proof index")
+
+		_, errMove := tf2bdd.MoveProof(ctx, database, sid, "0", index, 1)
+		require.ErrorContains(t, errMove, "invalid proof index")
+	}
+
+	_, errUnlisted := tf2bdd.MoveProof(ctx, database, steamid.New(76561197960265729), "0", "1", 1)
+	require.ErrorIs(t, errUnlisted, tf2bdd.ErrNotFound)
+
+	require.Equal(t, []string{"first", "second", "third"}, proof())
+
+	_, errMove := tf2bdd.MoveProof(ctx, database, sid, "2", "0", 1)
+	require.NoError(t, errMove)
+	require.Equal(t, []string{"third", "first", "second"}, proof())
+
+	_, errEdit := tf2bdd.EditProof(ctx, database, sid, "1", "edited | with a note", 1)
+	require.NoError(t, errEdit)
+	require.Equal(t, []string{"third", "edited", "second"}, proof())
+
+	_, errRemove := tf2bdd.RemoveProof(ctx, database, sid, "0", 1)
+	require.NoError(t, errRemove)
+	require.Equal(t, []string{"edited", "second"}, proof())
+
+	// Every change is recorded in the audit log, failed ones are not.
+	entries, errEntries := tf2bdd.GetAuditLog(ctx, database, sid, 10)
+	require.NoError(t, errEntries)
+	require.Len(t, entries, 4)
+
+	for _, entry := range entries[:3] {
+		require.Equal(t, "update", entry.Action)
+		require.Equal(t, int64(1), entry.Author)
+	}
+
+	require.Len(t, entries[0].Before.Proof, 3)
+	require.Len(t, entries[0].After.Proof, 2)
+	require.Equal(t, "with a note", entries[1].After.Proof[1].Note)
+}
+
+func TestReviewReport(t *testing.T) {
+	testConfig := tf2bdd.Config{KnownAttributes: []string{"cheater", "suspicious"}}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	deleted := steamid.New(76561197960287930)
+	reported := steamid.New(76561197960265729)
+
+	require.NoError(t, tf2bdd.AddPlayer(ctx, database, tf2bdd.Player{SteamID: deleted, Attributes: []string{"cheater"}}, 0))
+	require.NoError(t, tf2bdd.DropPlayer(ctx, database, deleted, 1))
+
+	addReport := func(sid steamid.SteamID) int64 {
+		report := tf2bdd.PlayerReport{
+			SteamID:    sid,
+			Attributes: []string{"suspicious"},
+			Proof:      tf2bdd.ProofEntry{Value: "https://example.com/demo"},
+			Author:     2,
+		}
+		require.NoError(t, tf2bdd.AddReport(ctx, database, &report))
+
+		return report.ReportID
+	}
+
+	// Reports of deleted entries are resolved, rather than staying pending forever.
+	deletedReport := addReport(deleted)
+	report, errReview := tf2bdd.ReviewReport(ctx, database, testConfig, "", deletedReport, 1, true)
+	require.NoError(t, errReview)
+	require.EqualValues(t, "duplicate", report.Status)
+
+	_, errDeleted := tf2bdd.GetPlayer(ctx, database, deleted)
+	require.ErrorIs(t, errDeleted, tf2bdd.ErrNotFound)
+
+	_, errReviewed := tf2bdd.ReviewReport(ctx, database, testConfig, "", deletedReport, 1, true)
+	require.ErrorIs(t, errReviewed, tf2bdd.ErrReportReviewed)
+
+	report, errReview = tf2bdd.ReviewReport(ctx, database, testConfig, "", addReport(reported), 1, true)
+	require.NoError(t, errReview)
+	require.EqualValues(t, "approved", report.Status)
+
+	player, errPlayer := tf2bdd.GetPlayer(ctx, database, reported)
+	require.NoError(t, errPlayer)
+	require.Equal(t, []string{"suspicious"}, player.Attributes)
+	require.Equal(t, int64(2), player.Author)
+
+	report, errReview = tf2bdd.ReviewReport(ctx, database, testConfig, "", addReport(reported), 1, true)
+	require.NoError(t, errReview)
+	require.EqualValues(t, "duplicate", report.Status)
+
+	report, errReview = tf2bdd.ReviewReport(ctx, database, testConfig, "", addReport(steamid.New(76561197960265730)), 1, false)
+	require.NoError(t, errReview)
+	require.EqualValues(t, "rejected", report.Status)
+}
+
+func TestLobbyOffer(t *testing.T) {
+	testConfig := tf2bdd.Config{KnownAttributes: []string{"cheater", "suspicious"}}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	newOffer := func(sid steamid.SteamID) int64 {
+		offer := tf2bdd.LobbyOffer{
+			Players:   []tf2bdd.LobbyPlayer{{SteamID: sid, Name: "bot"}},
+			Attribute: "cheater",
+			Author:    1,
+		}
+		require.NoError(t, tf2bdd.AddLobbyOffer(ctx, database, &offer))
+
+		return offer.LobbyID
+	}
+
+	countOffers := func() int {
+		var count int
+		require.NoError(t, database.QueryRowContext(ctx, `SELECT count(*) FROM lobby`).Scan(&count))
+
+		return count
+	}
+
+	expired := newOffer(steamid.New(76561197960287930))
+	_, errExec := database.ExecContext(ctx, `UPDATE lobby SET created_on = ? WHERE lobby_id = ?`,
+		time.Now().Add(-2*time.Hour).Unix(), expired)
+	require.NoError(t, errExec)
+
+	_, _, errExpired := tf2bdd.AddLobbyPlayers(ctx, database, testConfig, expired, 2)
+	require.ErrorIs(t, errExpired, tf2bdd.ErrNotFound)
+
+	// Expired offers are removed once a new one is created.
+	lobbyID := newOffer(steamid.New(76561197960265729))
+	require.Equal(t, 1, countOffers())
+
+	_, added, errAdd := tf2bdd.AddLobbyPlayers(ctx, database, testConfig, lobbyID, 2)
+	require.NoError(t, errAdd)
+	require.Equal(t, []steamid.SteamID{steamid.New(76561197960265729)}, added)
+
+	_, _, errAdded := tf2bdd.AddLobbyPlayers(ctx, database, testConfig, lobbyID, 2)
+	require.ErrorIs(t, errAdded, tf2bdd.ErrLobbyAdded)
+
+	_, errExpiredPlayer := tf2bdd.GetPlayer(ctx, database, steamid.New(76561197960287930))
+	require.ErrorIs(t, errExpiredPlayer, tf2bdd.ErrNotFound)
+}
@@ -0,0 +1,261 @@
+package tf2bdd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leighmacdonald/steamid/v4/steamid"
+)
+
+const (
+	// maxBulkEntries limits the amount of steam ids that can be added or deleted by a single command.
+	maxBulkEntries = 25
+	// resolveParallelism limits the amount of steam ids, such as vanity names, resolved at once.
+	resolveParallelism = 4
+)
+
+type bulkStatus string
+
+const (
+	bulkAdded             bulkStatus = "added"
+	bulkDeleted           bulkStatus = "deleted"
+	bulkDuplicate         bulkStatus = "duplicate"
+	bulkPreviouslyDeleted bulkStatus = "previously deleted, use !restore"
+	bulkNotFound          bulkStatus = "not found"
+	bulkUnresolvable      bulkStatus = "unresolvable"
+)
+
+// bulkResult is the outcome for a single steam id given to a bulk command.
+type bulkResult struct {
+	input   string
+	steamID steamid.SteamID
+	status  bulkStatus
+}
+
+// splitBulkArgs separates the steam ids from the attributes given to a bulk command. The first argument is
+// always a steam id, the following ones are attributes when they are known attributes, or close enough to one
+// to be a likely typo so that NormalizeAttributes can suggest a correction. This is decided before resolving,
+// so a mistyped attribute that happens to also be a vanity name is never added as a player.
+func splitBulkArgs(known []string, args []string) ([]string, []string) {
+	var steamIDs, attributes []string
+
+	for idx, arg := range strings.Fields(strings.Join(args, " ")) {
+		if idx > 0 && closestAttribute(known, strings.ToLower(arg)) != "" {
+			attributes = append(attributes, arg)
+
+			continue
+		}
+
+		steamIDs = append(steamIDs, arg)
+	}
+
+	return steamIDs, attributes
+}
+
+// resolveSteamIDs resolves the steam ids, which can be in any format, concurrently. Inputs resolving to a
+// steam id given earlier are marked as duplicates.
+func resolveSteamIDs(ctx context.Context, inputs []string) []bulkResult {
+	results := make([]bulkResult, len(inputs))
+	semaphore := make(chan struct{}, resolveParallelism)
+
+	var waitGroup sync.WaitGroup
+
+	for idx, input := range inputs {
+		results[idx].input = input
+
+		waitGroup.Add(1)
+
+		go func() {
+			defer waitGroup.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			resolveCtx, cancel := context.WithTimeout(ctx, time.Second*10)
+			defer cancel()
+
+			sid, errSid := steamid.Resolve(resolveCtx, input)
+			if errSid != nil || !sid.Valid() {
+				results[idx].status = bulkUnresolvable
+
+				return
+			}
+
+			results[idx].steamID = sid
+		}()
+	}
+
+	waitGroup.Wait()
+
+	seen := map[int64]bool{}
+
+	for idx, result := range results {
+		if result.status != "" {
+			continue
+		}
+
+		if seen[result.steamID.Int64()] {
+			results[idx].status = bulkDuplicate
+		}
+
+		seen[result.steamID.Int64()] = true
+	}
+
+	return results
+}
+
+// addIfUnlisted adds the player, along with the authors confirmation when confirmations are required,
+// unless an entry already exists for it. Entries that were deleted, but not yet purged, also count as existing.
+func addIfUnlisted(ctx context.Context, db querier, config Config, player Player, author int64) (bulkStatus, error) {
+	var deletedOn int64
+
+	errExisting := db.QueryRowContext(ctx, `SELECT deleted_on FROM player WHERE steamid = ?`,
+		player.SteamID.Int64()).Scan(&deletedOn)
+
+	switch {
+	case errExisting == nil && deletedOn > 0:
+		return bulkPreviouslyDeleted, nil
+	case errExisting == nil:
+		return bulkDuplicate, nil
+	case !errors.Is(errExisting, sql.ErrNoRows):
+		return "", errors.Join(errExisting, errors.New("failed to check for existing player"))
+	}
+
+	player.Author = author
+	player.Confirmed = !config.confirmationsRequired()
+
+	if errAdd := addPlayer(ctx, db, player, author, auditAdd); errAdd != nil {
+		return "", errAdd
+	}
+
+	if !player.Confirmed {
+		// The author counts as the first confirmation.
+		if errConfirm := addConfirmation(ctx, db, player.SteamID, author); errConfirm != nil {
+			return "", errConfirm
+		}
+	}
+
+	return bulkAdded, nil
+}
+
+// addEntries adds every resolved steam id with the same attributes in a single transaction.
+func addEntries(ctx context.Context, database *sql.DB, config Config, results []bulkResult, attributes []string,
+	guildID string, author int64,
+) error {
+	return withTx(ctx, database, func(tx *sql.Tx) error {
+		for idx, result := range results {
+			if result.status != "" {
+				continue
+			}
+
+			player := Player{
+				SteamID:    result.steamID,
+				Attributes: attributes,
+				LastSeen:   LastSeen{Time: time.Now().Unix()},
+				Proof:      Proof{},
+				GuildID:    config.entryGuild(guildID),
+			}
+
+			status, errAdd := addIfUnlisted(ctx, tx, config, player, author)
+			if errAdd != nil {
+				return errAdd
+			}
+
+			results[idx].status = status
+		}
+
+		return nil
+	})
+}
+
+// deleteEntries deletes every resolved steam id in a single transaction.
+func deleteEntries(ctx context.Context, database *sql.DB, results []bulkResult, author int64) error {
+	return withTx(ctx, database, func(tx *sql.Tx) error {
+		for idx, result := range results {
+			if result.status != "" {
+				continue
+			}
+
+			if errDrop := dropPlayer(ctx, tx, result.steamID, author); errDrop != nil {
+				if !errors.Is(errDrop, ErrNotFound) {
+					return errDrop
+				}
+
+				results[idx].status = bulkNotFound
+
+				continue
+			}
+
+			results[idx].status = bulkDeleted
+		}
+
+		return nil
+	})
+}
+
+// bulkEntries adds or deletes several steam ids at once, replying with the outcome for each of them.
+func bulkEntries(ctx context.Context, database *sql.DB, config Config, command string, steamIDs []string,
+	attributes []string, defaultAttr string, guildID string, author int64,
+) ([]bulkResult, string, error) {
+	if len(steamIDs) > maxBulkEntries {
+		return nil, "", fmt.Errorf("too many steam ids, at most %d can be changed at once", maxBulkEntries)
+	}
+
+	if command == "del" && len(attributes) > 0 {
+		return nil, "", errors.New("attributes cannot be used when deleting entries")
+	}
+
+	attrs, errAttrs := NormalizeAttributes(config.KnownAttributes, attributes)
+	if errAttrs != nil {
+		return nil, "", errAttrs
+	}
+
+	if len(attrs) == 0 {
+		attrs = append(attrs, defaultAttr)
+	}
+
+	results := resolveSteamIDs(ctx, steamIDs)
+
+	var errWrite error
+	if command == "del" {
+		errWrite = deleteEntries(ctx, database, results, author)
+	} else {
+		errWrite = addEntries(ctx, database, config, results, attrs, guildID, author)
+	}
+
+	if errWrite != nil {
+		return nil, "", errWrite
+	}
+
+	counts := map[bulkStatus]int{}
+	for _, result := range results {
+		counts[result.status]++
+	}
+
+	var builder strings.Builder
+	if command == "del" {
+		builder.WriteString(fmt.Sprintf("**Deleted %d**, not found %d, unresolvable %d\n",
+			counts[bulkDeleted], counts[bulkNotFound], counts[bulkUnresolvable]))
+	} else {
+		builder.WriteString(fmt.Sprintf("**Added %d** as `%s`, duplicate %d, unresolvable %d\n",
+			counts[bulkAdded], strings.Join(attrs, ", "), counts[bulkDuplicate]+counts[bulkPreviouslyDeleted],
+			counts[bulkUnresolvable]))
+	}
+
+	for _, result := range results {
+		if result.status == bulkUnresolvable {
+			builder.WriteString(fmt.Sprintf("`%s` %s\n", result.input, result.status))
+
+			continue
+		}
+
+		builder.WriteString(fmt.Sprintf("`%s` %s %s\n", result.input, result.steamID.String(), result.status))
+	}
+
+	return results, builder.String(), nil
+}
@@ -0,0 +1,236 @@
+package tf2bdd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/leighmacdonald/steamid/v4/steamid"
+)
+
+// maxChanges limits the amount of changes returned at once. Clients continue from the returned cursor
+// to fetch the rest.
+const maxChanges = 1000
+
+type changeAction string
+
+const (
+	changeAdd    changeAction = "add"
+	changeUpdate changeAction = "update"
+	changeDelete changeAction = "delete"
+)
+
+// PlayerChange is the latest change of a player since the requested cursor. Players that are deleted,
+// or no longer part of the list, are sent as a delete without the player.
+type PlayerChange struct {
+	Seq     int64           `json:"seq"`
+	Action  changeAction    `json:"action"`
+	SteamID steamid.SteamID `json:"steamid"`
+	Player  *Player         `json:"player,omitempty"`
+}
+
+// ChangeSet is the response of the changes endpoint.
+type ChangeSet struct {
+	// Cursor should be sent as the since parameter of the next request.
+	Cursor int64 `json:"cursor"`
+	// More is set when there are more changes available after the cursor.
+	More bool `json:"more"`
+	// ResyncRequired is set when the cursor is older than the retained deletions. The full list must be
+	// downloaded again, after which changes can be fetched using the returned cursor.
+	ResyncRequired bool           `json:"resync_required"`
+	Changes        []PlayerChange `json:"changes"`
+}
+
+// setChangeSeq updates the change sequence of a player after a mutation. The sequence uses the id of
+// the audit log entry recording the change, so it increases monotonically with every write.
+func setChangeSeq(ctx context.Context, db querier, action auditAction, steamID steamid.SteamID, seq int64) error {
+	query := `UPDATE player SET change_seq = ? WHERE steamid = ?`
+	args := []any{seq, steamID.Int64()}
+
+	switch action {
+	case auditPurge:
+		// The row no longer exists, see setPurgedSeq.
+		return nil
+	case auditAdd, auditImport, auditApprove, auditRestore:
+		query = `UPDATE player SET change_seq = ?, added_seq = ? WHERE steamid = ?`
+		args = []any{seq, seq, steamID.Int64()}
+	}
+
+	if _, errExec := db.ExecContext(ctx, query, args...); errExec != nil {
+		return errors.Join(errExec, errors.New("failed to update change sequence"))
+	}
+
+	return nil
+}
+
+// setPurgedSeq records the change sequence of a soft deleted player before it is permanently removed.
+// Clients with a cursor before it can no longer be sent the deletion and must resync.
+func setPurgedSeq(ctx context.Context, db querier, steamID steamid.SteamID) error {
+	const query = `
+		UPDATE sync_state
+		SET purged_seq = max(purged_seq, (SELECT change_seq FROM player WHERE steamid = ?))`
+
+	if _, errExec := db.ExecContext(ctx, query, steamID.Int64()); errExec != nil {
+		return errors.Join(errExec, errors.New("failed to update purged sequence"))
+	}
+
+	return nil
+}
+
+type changeRow struct {
+	steamID  int64
+	seq      int64
+	addedSeq int64
+	deleted  bool
+}
+
+// getChanges returns the players of the list that changed after the since cursor, in the order they
+// were changed. A since value of 0 returns every player.
+func getChanges(ctx context.Context, db querier, list ListConfig, since int64, filter listFilter) (ChangeSet, error) {
+	changes := ChangeSet{Cursor: since, Changes: []PlayerChange{}}
+
+	var purgedSeq int64
+	if errPurged := db.QueryRowContext(ctx, `SELECT purged_seq FROM sync_state`).Scan(&purgedSeq); errPurged != nil {
+		return ChangeSet{}, errors.Join(errPurged, errors.New("failed to load purged sequence"))
+	}
+
+	latest, _, errVersion := dataVersion(ctx, db)
+	if errVersion != nil {
+		return ChangeSet{}, errVersion
+	}
+
+	// A cursor ahead of the latest change can only come from a different, or restored, database.
+	if since > 0 && (since < purgedSeq || since > latest) {
+		changes.Cursor = latest
+		changes.ResyncRequired = true
+
+		return changes, nil
+	}
+
+	rows, errRows := getChangeRows(ctx, db, since)
+	if errRows != nil {
+		return ChangeSet{}, errRows
+	}
+
+	changes.Cursor = latest
+
+	if since > 0 && len(rows) > maxChanges {
+		changes.More = true
+		rows = rows[:maxChanges]
+		changes.Cursor = rows[len(rows)-1].seq
+	}
+
+	if len(rows) == 0 {
+		return changes, nil
+	}
+
+	const query = `SELECT ` + playerColumns + ` FROM player WHERE (change_seq > ? OR ? = 0) AND change_seq <= ?`
+
+	players, errPlayers := queryPlayers(ctx, db, query, since, since, rows[len(rows)-1].seq)
+	if errPlayers != nil {
+		return ChangeSet{}, errPlayers
+	}
+
+	playerMap := make(map[int64]Player, len(players))
+	for _, player := range players {
+		playerMap[player.SteamID.Int64()] = player
+	}
+
+	for _, row := range rows {
+		change := PlayerChange{Seq: row.seq, Action: changeDelete, SteamID: steamid.New(row.steamID)}
+
+		if player, found := playerMap[row.steamID]; found && !row.deleted && list.includes(player, filter) {
+			change.Player = &player
+			change.Action = changeUpdate
+
+			if since == 0 || row.addedSeq > since {
+				change.Action = changeAdd
+			}
+		}
+
+		changes.Changes = append(changes.Changes, change)
+	}
+
+	return changes, nil
+}
+
+// getChangeRows returns up to maxChanges + 1 changed players so callers can tell if there are more.
+// Every player is returned when since is 0, as entries that predate the audit log share a change
+// sequence of 0 and cannot be paged through.
+func getChangeRows(ctx context.Context, db querier, since int64) ([]changeRow, error) {
+	const query = `
+		SELECT steamid, change_seq, added_seq, deleted_on > 0
+		FROM player
+		WHERE change_seq > ? OR ? = 0
+		ORDER BY change_seq, steamid
+		LIMIT ?`
+
+	limit := maxChanges + 1
+	if since == 0 {
+		limit = -1
+	}
+
+	rows, errQuery := db.QueryContext(ctx, query, since, since, limit)
+	if errQuery != nil {
+		return nil, errors.Join(errQuery, errors.New("failed to load changes"))
+	}
+
+	defer func() {
+		if errClose := rows.Close(); errClose != nil {
+			slog.Error("Failed to close rows handle", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	var changeRows []changeRow
+
+	for rows.Next() {
+		var row changeRow
+		if errScan := rows.Scan(&row.steamID, &row.seq, &row.addedSeq, &row.deleted); errScan != nil {
+			return nil, errors.Join(errScan, errors.New("error scanning change row"))
+		}
+
+		changeRows = append(changeRows, row)
+	}
+
+	if rows.Err() != nil {
+		return nil, errors.Join(rows.Err(), errors.New("error reading change rows"))
+	}
+
+	return changeRows, nil
+}
+
+// handleGetChanges serves the changes made to the default list after the cursor given by the since
+// parameter, allowing mirrors to stay in sync without downloading the full list.
+func handleGetChanges(database *sql.DB, config Config) http.HandlerFunc {
+	list := config.defaultList()
+
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var since int64
+
+		if value := request.URL.Query().Get("since"); value != "" {
+			parsed, errParse := strconv.ParseInt(value, 10, 64)
+			if errParse != nil || parsed < 0 {
+				writeError(writer, http.StatusBadRequest, "invalid since cursor")
+
+				return
+			}
+
+			since = parsed
+		}
+
+		filter := parseListFilter(request)
+
+		changes, errChanges := getChanges(request.Context(), database, list, since, filter)
+		if errChanges != nil {
+			slog.Error("Failed to load changes", slog.String("error", errChanges.Error()))
+			writeError(writer, http.StatusInternalServerError, "Could not load changes")
+
+			return
+		}
+
+		writeJSON(writer, http.StatusOK, &changes)
+	}
+}
@@ -5,34 +5,183 @@
 	"fmt"
 	"net"
 	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
 )
 
-var errConfigFile = errors.New("configuration file invalid")
+// RunMode controls which subsystems are started.
+type RunMode string
+
+const (
+	// ModeBoth runs both the discord bot and the http server.
+	ModeBoth RunMode = "both"
+	// ModeServe runs only the http server, for read-only mirrors of the list.
+	ModeServe RunMode = "serve"
+	// ModeBot runs only the discord bot.
+	ModeBot RunMode = "bot"
+)
+
+var (
+	errConfigFile = errors.New("configuration file invalid")
+	reListName    = regexp.MustCompile(`^[a-z0-9_-]+$`)
+)
+
+// GuildConfig authorizes a discord guild to use the bot, optionally with its own role configuration as
+// role ids are unique to each guild.
+type GuildConfig struct {
+	GuildID      string              `mapstructure:"guild_id"`
+	Name         string              `mapstructure:"name"`
+	DiscordRoles []string            `mapstructure:"discord_roles"`
+	CommandRoles map[string][]string `mapstructure:"command_roles"`
+}
+
+// ListConfig defines an additional player list, served at /v1/lists/{name}. It contains every
+// player with at least one of its attributes.
+type ListConfig struct {
+	Name        string   `mapstructure:"name"`
+	Title       string   `mapstructure:"title"`
+	Description string   `mapstructure:"description"`
+	Authors     []string `mapstructure:"authors"`
+	Attributes  []string `mapstructure:"attributes"`
+	ChannelID   string   `mapstructure:"channel_id"`
+	GuildID     string   `mapstructure:"guild_id"`
+}
+
+// UpstreamTrust controls how entries from an upstream list are published.
+type UpstreamTrust string
+
+const (
+	// TrustFull publishes upstream entries immediately.
+	TrustFull UpstreamTrust = "full"
+	// TrustConfirm holds upstream entries as unconfirmed until they are confirmed locally.
+	TrustConfirm UpstreamTrust = "confirm"
+)
+
+// UpstreamConfig defines another tf2bdd, or compatible, player list that is periodically merged into
+// the local list.
+type UpstreamConfig struct {
+	Name     string        `mapstructure:"name"`
+	URL      string        `mapstructure:"url"`
+	Interval time.Duration `mapstructure:"interval"`
+	Trust    UpstreamTrust `mapstructure:"trust"`
+	// Attributes maps upstream attributes to local ones. Upstream attributes that are not mapped are
+	// dropped. When empty, attributes that are defined in known_attributes are kept as is.
+	Attributes map[string]string `mapstructure:"attributes"`
+}
 
 type Config struct {
-	SteamKey        string   `mapstructure:"steam_key"`
-	DiscordClientID string   `mapstructure:"discord_client_id"`
-	DiscordBotToken string   `mapstructure:"discord_bot_token"`
-	DiscordRoles    []string `mapstructure:"discord_roles"`
-	ExternalURL     string   `mapstructure:"external_url"`
-	DatabasePath    string   `mapstructure:"database_path"`
-	ListenHost      string   `mapstructure:"listen_host"`
-	ListenPort      uint16   `mapstructure:"listen_port"`
-	ListTitle       string   `mapstructure:"list_title"`
-	ListDescription string   `mapstructure:"list_description"`
-	ListAuthors     []string `mapstructure:"list_authors"`
-	ExportedAttrs   []string `mapstructure:"exported_attrs"`
+	Mode                  RunMode             `mapstructure:"mode"`
+	SteamKey              string              `mapstructure:"steam_key"`
+	SteamAPIURL           string              `mapstructure:"steam_api_url"`
+	EnrichInterval        time.Duration       `mapstructure:"enrich_interval"`
+	DiscordClientID       string              `mapstructure:"discord_client_id"`
+	DiscordBotToken       string              `mapstructure:"discord_bot_token"`
+	DiscordRoles          []string            `mapstructure:"discord_roles"`
+	CommandRoles          map[string][]string `mapstructure:"command_roles"`
+	ExternalURL           string              `mapstructure:"external_url"`
+	DatabasePath          string              `mapstructure:"database_path"`
+	ListenHost            string              `mapstructure:"listen_host"`
+	ListenPort            uint16              `mapstructure:"listen_port"`
+	ListTitle             string              `mapstructure:"list_title"`
+	ListDescription       string              `mapstructure:"list_description"`
+	ListAuthors           []string            `mapstructure:"list_authors"`
+	ExportedAttrs         []string            `mapstructure:"exported_attrs"`
+	KnownAttributes       []string            `mapstructure:"known_attributes"`
+	PurgeDeletedAfter     time.Duration       `mapstructure:"purge_deleted_after"`
+	ProofDir              string              `mapstructure:"proof_dir"`
+	ProofMaxSize          int64               `mapstructure:"proof_max_size"`
+	ProofAllowedTypes     []string            `mapstructure:"proof_allowed_types"`
+	ReviewChannelID       string              `mapstructure:"review_channel_id"`
+	RequiredConfirmations int                 `mapstructure:"required_confirmations"`
+	Lists                 []ListConfig        `mapstructure:"lists"`
+	Guilds                []GuildConfig       `mapstructure:"guilds"`
+	TagEntries            bool                `mapstructure:"tag_entries"`
+	Upstreams             []UpstreamConfig    `mapstructure:"upstreams"`
+}
+
+// RunsBot returns true if the discord bot should be started.
+func (config Config) RunsBot() bool {
+	return config.Mode == ModeBoth || config.Mode == ModeBot
+}
+
+// RunsHTTP returns true if the http server should be started.
+func (config Config) RunsHTTP() bool {
+	return config.Mode == ModeBoth || config.Mode == ModeServe
 }
 
 func (config Config) ListenAddr() string {
 	return net.JoinHostPort(config.ListenHost, fmt.Sprintf("%d", config.ListenPort))
 }
 
-func (config Config) UpdateURL() (string, error) {
+// publicCommands are commands that anyone can use unless restricted via command_roles.
+var publicCommands = []string{"steamid", "count", "report"}
+
+// guild returns the configuration of the guild, if it has one.
+func (config Config) guild(guildID string) (GuildConfig, bool) {
+	for _, guild := range config.Guilds {
+		if guild.GuildID == guildID {
+			return guild, true
+		}
+	}
+
+	return GuildConfig{}, false
+}
+
+// GuildAllowed returns true if the bot is allowed to operate within the guild. When no guilds are
+// configured, all guilds are allowed.
+func (config Config) GuildAllowed(guildID string) bool {
+	if len(config.Guilds) == 0 {
+		return true
+	}
+
+	_, found := config.guild(guildID)
+
+	return found
+}
+
+// RolesForCommand returns the role ids within the guild that are allowed to use the command. If nil is
+// returned with a true value, the command is open to everyone. Guild specific roles take precedence
+// over the global ones.
+func (config Config) RolesForCommand(guildID string, command string) ([]string, bool) {
+	guild, isGuild := config.guild(guildID)
+	if isGuild {
+		if roles, found := guild.CommandRoles[command]; found {
+			return roles, false
+		}
+	}
+
+	if roles, found := config.CommandRoles[command]; found {
+		return roles, false
+	}
+
+	if slices.Contains(publicCommands, command) {
+		return nil, true
+	}
+
+	if isGuild && len(guild.DiscordRoles) > 0 {
+		return guild.DiscordRoles, false
+	}
+
+	return config.DiscordRoles, false
+}
+
+// entryGuild returns the guild that new entries are tagged with, which is empty unless tag_entries is enabled.
+func (config Config) entryGuild(guildID string) string {
+	if !config.TagEntries {
+		return ""
+	}
+
+	return guildID
+}
+
+// externalURL returns the publicly reachable url for the path.
+func (config Config) externalURL(path string) (string, error) {
 	extURL := config.ExternalURL
 	if extURL == "" {
 		host := config.ListenHost
@@ -46,11 +195,46 @@ func (config Config) UpdateURL() (string, error) {
 	if errParse != nil {
 		return "", errParse
 	}
-	parsed.Path = "/v1/steamids"
+	parsed.Path = path
 
 	return parsed.String(), nil
 }
 
+func (config Config) UpdateURL() (string, error) {
+	return config.externalURL("/v1/steamids")
+}
+
+// ListURL returns the url that the named list is served from.
+func (config Config) ListURL(name string) (string, error) {
+	return config.externalURL("/v1/lists/" + name)
+}
+
+// listForChannel returns the list that is bound to the discord channel, if any.
+func (config Config) listForChannel(channelID string) (ListConfig, bool) {
+	for _, list := range config.Lists {
+		if list.ChannelID != "" && list.ChannelID == channelID {
+			return list, true
+		}
+	}
+
+	return ListConfig{}, false
+}
+
+// defaultAttribute returns the attribute used for new entries when none are provided. Channels bound to
+// a list default to the first attribute of that list.
+func (config Config) defaultAttribute(channelID string) string {
+	if list, found := config.listForChannel(channelID); found && len(list.Attributes) > 0 {
+		return list.Attributes[0]
+	}
+
+	return config.KnownAttributes[0]
+}
+
+// ProofURL returns the url that a locally stored proof attachment is served from.
+func (config Config) ProofURL(hash string) (string, error) {
+	return config.externalURL("/v1/proof/" + hash)
+}
+
 func ReadConfig() (Config, error) {
 	if home, errHomeDir := homedir.Dir(); errHomeDir != nil {
 		viper.AddConfigPath(home)
@@ -64,18 +248,36 @@ func ReadConfig() (Config, error) {
 	viper.AutomaticEnv()
 
 	defaultValues := map[string]any{
-		"steam_key":         "",
-		"discord_client_id": "",
-		"discord_bot_token": "",
-		"discord_roles":     []string{},
-		"external_url":      "",
-		"database_path":     "./db.sqlite",
-		"listen_host":       "localhost",
-		"listen_port":       8899,
-		"list_title":        "",
-		"list_description":  "",
-		"list_authors":      []string{"anonymous"},
-		"exported_attrs":    []string{},
+		"mode":                   ModeBoth,
+		"steam_key":              "",
+		"steam_api_url":          "https://api.steampowered.com",
+		"enrich_interval":        "24h",
+		"discord_client_id":      "",
+		"discord_bot_token":      "",
+		"discord_roles":          []string{},
+		"command_roles":          map[string][]string{},
+		"external_url":           "",
+		"database_path":          "./db.sqlite",
+		"listen_host":            "localhost",
+		"listen_port":            8899,
+		"list_title":             "",
+		"list_description":       "",
+		"list_authors":           []string{"anonymous"},
+		"exported_attrs":         []string{},
+		"known_attributes":       []string{"cheater", "suspicious", "exploiter", "racist"},
+		"purge_deleted_after":    "720h",
+		"review_channel_id":      "",
+		"required_confirmations": 0,
+		"lists":                  []map[string]any{},
+		"guilds":                 []map[string]any{},
+		"tag_entries":            false,
+		"upstreams":              []map[string]any{},
+		"proof_dir":              "./proof",
+		"proof_max_size":         25 * 1024 * 1024,
+		"proof_allowed_types": []string{
+			"image/png", "image/jpeg", "image/gif", "image/webp",
+			"video/mp4", "video/webm", "application/octet-stream",
+		},
 	}
 
 	for configKey, value := range defaultValues {
@@ -94,7 +296,90 @@ func ReadConfig() (Config, error) {
 	return config, nil
 }
 
+// ValidateConfig checks the config, only requiring the options used by the configured mode.
 func ValidateConfig(config Config) error {
+	switch config.Mode {
+	case ModeBoth, ModeServe, ModeBot:
+	default:
+		return fmt.Errorf("invalid mode, must be one of both, serve or bot: %s", config.Mode)
+	}
+
+	if config.RunsBot() {
+		if errBot := validateBotConfig(config); errBot != nil {
+			return errBot
+		}
+	}
+
+	if len(config.KnownAttributes) == 0 {
+		return errors.New("known_attributes cannot be empty")
+	}
+
+	for _, attr := range config.KnownAttributes {
+		if attr == "" || attr != strings.ToLower(attr) || strings.ContainsAny(attr, " ,") {
+			return fmt.Errorf("known_attributes: invalid attribute, must be lowercase without spaces or commas: %s", attr)
+		}
+	}
+
+	for _, attr := range config.ExportedAttrs {
+		if !slices.Contains(config.KnownAttributes, attr) {
+			return fmt.Errorf("exported_attrs: attribute is not defined in known_attributes: %s", attr)
+		}
+	}
+
+	if config.PurgeDeletedAfter < 0 {
+		return errors.New("purge_deleted_after cannot be negative")
+	}
+
+	if config.EnrichInterval < 0 {
+		return errors.New("enrich_interval cannot be negative")
+	}
+
+	if parsed, errURL := url.Parse(config.SteamAPIURL); errURL != nil ||
+		(parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid steam_api_url: %s", config.SteamAPIURL)
+	}
+
+	if errLists := validateLists(config); errLists != nil {
+		return errLists
+	}
+
+	if errUpstreams := validateUpstreams(config); errUpstreams != nil {
+		return errUpstreams
+	}
+
+	if config.RequiredConfirmations < 0 {
+		return errors.New("required_confirmations cannot be negative")
+	}
+
+	if config.ProofDir == "" {
+		return errors.New("proof_dir cannot be empty")
+	}
+
+	if config.ProofMaxSize <= 0 {
+		return errors.New("proof_max_size must be greater than 0")
+	}
+
+	for _, contentType := range config.ProofAllowedTypes {
+		if strings.HasPrefix(contentType, "text/") {
+			return fmt.Errorf("proof_allowed_types: text types cannot be served safely: %s", contentType)
+		}
+	}
+
+	if config.RunsHTTP() {
+		if len(config.ListTitle) == 0 {
+			return errors.New("list_title cannot be empty")
+		}
+
+		if len(config.ListDescription) == 0 {
+			return errors.New("list_description cannot be empty")
+		}
+	}
+
+	return nil
+}
+
+// validateBotConfig checks the options that are only used by the discord bot.
+func validateBotConfig(config Config) error {
 	if config.SteamKey == "" || len(config.SteamKey) != 32 {
 		return fmt.Errorf("invalid steam token: %s", config.SteamKey)
 	}
@@ -107,16 +392,157 @@ func ValidateConfig(config Config) error {
 		return errors.New("discord bot token not set")
 	}
 
-	if len(config.DiscordRoles) == 0 {
+	if errGuilds := validateGuilds(config); errGuilds != nil {
+		return errGuilds
+	}
+
+	if len(config.DiscordRoles) == 0 && !slices.ContainsFunc(config.Guilds, func(guild GuildConfig) bool {
+		return len(guild.DiscordRoles) > 0
+	}) {
 		return errors.New("no discord roles are defined")
 	}
 
-	if len(config.ListTitle) == 0 {
-		return errors.New("list_title cannot be empty")
+	if errCommandRoles := validateCommandRoles("command_roles", config.CommandRoles); errCommandRoles != nil {
+		return errCommandRoles
+	}
+
+	return nil
+}
+
+func validateCommandRoles(prefix string, commandRoles map[string][]string) error {
+	for command, roles := range commandRoles {
+		if _, found := commandMinArgs[command]; !found && command != reviewPermission {
+			return fmt.Errorf("%s: unknown command: %s", prefix, command)
+		}
+
+		if len(roles) == 0 {
+			return fmt.Errorf("%s: no roles defined for command: %s", prefix, command)
+		}
+
+		for _, roleID := range roles {
+			if _, errRole := strconv.ParseUint(roleID, 10, 64); errRole != nil {
+				return fmt.Errorf("%s: invalid role id for command %s: %s", prefix, command, roleID)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateGuilds(config Config) error {
+	seen := map[string]bool{}
+
+	for _, guild := range config.Guilds {
+		if _, errGuild := strconv.ParseUint(guild.GuildID, 10, 64); errGuild != nil {
+			return fmt.Errorf("guilds: invalid guild id: %s", guild.GuildID)
+		}
+
+		if seen[guild.GuildID] {
+			return fmt.Errorf("guilds: duplicate guild id: %s", guild.GuildID)
+		}
+
+		seen[guild.GuildID] = true
+
+		for _, roleID := range guild.DiscordRoles {
+			if _, errRole := strconv.ParseUint(roleID, 10, 64); errRole != nil {
+				return fmt.Errorf("guilds: invalid role id for guild %s: %s", guild.GuildID, roleID)
+			}
+		}
+
+		if errCommandRoles := validateCommandRoles("guilds: "+guild.GuildID+": command_roles", guild.CommandRoles); errCommandRoles != nil {
+			return errCommandRoles
+		}
 	}
 
-	if len(config.ListDescription) == 0 {
-		return errors.New("list_description cannot be empty")
+	return nil
+}
+
+func validateLists(config Config) error {
+	names := map[string]bool{}
+	channels := map[string]bool{}
+
+	for _, list := range config.Lists {
+		if !reListName.MatchString(list.Name) {
+			return fmt.Errorf("lists: invalid name, must be lowercase letters, numbers, - or _: %s", list.Name)
+		}
+
+		if names[list.Name] {
+			return fmt.Errorf("lists: duplicate name: %s", list.Name)
+		}
+
+		names[list.Name] = true
+
+		if list.Title == "" {
+			return fmt.Errorf("lists: title cannot be empty: %s", list.Name)
+		}
+
+		if list.Description == "" {
+			return fmt.Errorf("lists: description cannot be empty: %s", list.Name)
+		}
+
+		for _, attr := range list.Attributes {
+			if !slices.Contains(config.KnownAttributes, attr) {
+				return fmt.Errorf("lists: attribute is not defined in known_attributes: %s: %s", list.Name, attr)
+			}
+		}
+
+		if list.GuildID != "" && !config.GuildAllowed(list.GuildID) {
+			return fmt.Errorf("lists: guild is not defined in guilds: %s: %s", list.Name, list.GuildID)
+		}
+
+		if list.ChannelID == "" {
+			continue
+		}
+
+		if _, errChannel := strconv.ParseUint(list.ChannelID, 10, 64); errChannel != nil {
+			return fmt.Errorf("lists: invalid channel id: %s: %s", list.Name, list.ChannelID)
+		}
+
+		if channels[list.ChannelID] {
+			return fmt.Errorf("lists: channel is bound to multiple lists: %s", list.ChannelID)
+		}
+
+		channels[list.ChannelID] = true
+	}
+
+	return nil
+}
+
+func validateUpstreams(config Config) error {
+	names := map[string]bool{}
+
+	for _, upstream := range config.Upstreams {
+		if !reListName.MatchString(upstream.Name) {
+			return fmt.Errorf("upstreams: invalid name, must be lowercase letters, numbers, - or _: %s", upstream.Name)
+		}
+
+		if names[upstream.Name] {
+			return fmt.Errorf("upstreams: duplicate name: %s", upstream.Name)
+		}
+
+		names[upstream.Name] = true
+
+		parsed, errURL := url.Parse(upstream.URL)
+		if errURL != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("upstreams: invalid url: %s: %s", upstream.Name, upstream.URL)
+		}
+
+		if upstream.Interval != 0 && upstream.Interval < time.Minute {
+			return fmt.Errorf("upstreams: interval must be at least 1m: %s", upstream.Name)
+		}
+
+		switch upstream.Trust {
+		case "", TrustFull, TrustConfirm:
+		default:
+			return fmt.Errorf("upstreams: invalid trust, must be one of full or confirm: %s: %s", upstream.Name, upstream.Trust)
+		}
+
+		for upstreamAttr, attr := range upstream.Attributes {
+			if !slices.Contains(config.KnownAttributes, attr) {
+				return fmt.Errorf("upstreams: attribute mapped from %s is not defined in known_attributes: %s: %s",
+					upstreamAttr, upstream.Name, attr)
+			}
+		}
 	}
 
 	return nil
@@ -0,0 +1,128 @@
+package tf2bdd_test
+
+import (
+	"testing"
+
+	"github.com/leighmacdonald/tf2bdd/tf2bdd"
+	"github.com/stretchr/testify/require"
+)
+
+// validConfig returns a config that passes validation, for tests changing a single option.
+func validConfig() tf2bdd.Config {
+	return tf2bdd.Config{
+		Mode:            tf2bdd.ModeBoth,
+		SteamKey:        "0123456789abcdef0123456789abcdef",
+		SteamAPIURL:     "https://api.steampowered.com",
+		DiscordClientID: "1234",
+		DiscordBotToken: "token",
+		DiscordRoles:    []string{"100"},
+		KnownAttributes: []string{"cheater", "suspicious"},
+		ProofDir:        "proof",
+		ProofMaxSize:    1024,
+		ListTitle:       "test title",
+		ListDescription: "test description",
+	}
+}
+
+func TestRolesForCommand(t *testing.T) {
+	config := tf2bdd.Config{
+		DiscordRoles: []string{"100"},
+		CommandRoles: map[string][]string{"add": {"200"}, "count": {"201"}},
+		Guilds: []tf2bdd.GuildConfig{
+			{GuildID: "1", DiscordRoles: []string{"300"}, CommandRoles: map[string][]string{"add": {"400"}}},
+			{GuildID: "2"},
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		guildID string
+		command string
+		roles   []string
+		public  bool
+	}{
+		{name: "guild command roles", guildID: "1", command: "add", roles: []string{"400"}},
+		{name: "global command roles", guildID: "2", command: "add", roles: []string{"200"}},
+		{name: "global command roles of unknown guild", guildID: "3", command: "add", roles: []string{"200"}},
+		{name: "global command roles restrict public", guildID: "1", command: "count", roles: []string{"201"}},
+		{name: "public command", guildID: "1", command: "steamid", public: true},
+		{name: "guild discord roles", guildID: "1", command: "del", roles: []string{"300"}},
+		{name: "discord roles of guild without roles", guildID: "2", command: "del", roles: []string{"100"}},
+		{name: "discord roles", guildID: "3", command: "del", roles: []string{"100"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			roles, public := config.RolesForCommand(testCase.guildID, testCase.command)
+			require.Equal(t, testCase.roles, roles)
+			require.Equal(t, testCase.public, public)
+		})
+	}
+}
+
+func TestValidateCommandRoles(t *testing.T) {
+	config := validConfig()
+	config.CommandRoles = map[string][]string{"add": {"200"}, "review": {"201"}}
+	require.NoError(t, tf2bdd.ValidateConfig(config))
+
+	config.CommandRoles = map[string][]string{"ad": {"200"}}
+	require.ErrorContains(t, tf2bdd.ValidateConfig(config), "unknown command: ad")
+
+	config.CommandRoles = map[string][]string{"add": {}}
+	require.ErrorContains(t, tf2bdd.ValidateConfig(config), "no roles defined")
+
+	config.CommandRoles = map[string][]string{"add": {"admins"}}
+	require.ErrorContains(t, tf2bdd.ValidateConfig(config), "invalid role id")
+
+	config.CommandRoles = nil
+	config.Guilds = []tf2bdd.GuildConfig{{GuildID: "1", CommandRoles: map[string][]string{"ad": {"200"}}}}
+	require.ErrorContains(t, tf2bdd.ValidateConfig(config), "unknown command: ad")
+}
+
+func TestValidateMode(t *testing.T) {
+	config := validConfig()
+	config.Mode = "mirror"
+	require.ErrorContains(t, tf2bdd.ValidateConfig(config), "invalid mode")
+
+	// The bot options are only required when the bot is started.
+	config = validConfig()
+	config.Mode = tf2bdd.ModeServe
+	config.SteamKey = ""
+	config.DiscordClientID = ""
+	config.DiscordBotToken = ""
+	config.DiscordRoles = nil
+	require.NoError(t, tf2bdd.ValidateConfig(config))
+
+	config.Mode = tf2bdd.ModeBoth
+	require.Error(t, tf2bdd.ValidateConfig(config))
+
+	// The list options are only required when the http server is started.
+	config = validConfig()
+	config.Mode = tf2bdd.ModeBot
+	config.ListTitle = ""
+	config.ListDescription = ""
+	require.NoError(t, tf2bdd.ValidateConfig(config))
+
+	config.Mode = tf2bdd.ModeServe
+	require.ErrorContains(t, tf2bdd.ValidateConfig(config), "list_title")
+}
+
+func TestRunMode(t *testing.T) {
+	testCases := []struct {
+		mode     tf2bdd.RunMode
+		runsBot  bool
+		runsHTTP bool
+	}{
+		{mode: tf2bdd.ModeBoth, runsBot: true, runsHTTP: true},
+		{mode: tf2bdd.ModeServe, runsHTTP: true},
+		{mode: tf2bdd.ModeBot, runsBot: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.mode), func(t *testing.T) {
+			config := tf2bdd.Config{Mode: testCase.mode}
+			require.Equal(t, testCase.runsBot, config.RunsBot())
+			require.Equal(t, testCase.runsHTTP, config.RunsHTTP())
+		})
+	}
+}
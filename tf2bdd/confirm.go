@@ -0,0 +1,220 @@
+package tf2bdd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/leighmacdonald/steamid/v4/steamid"
+)
+
+var ErrAlreadyConfirmed = errors.New("entry is already confirmed")
+
+// confirmEmoji is the reaction used on announcements to confirm an entry.
+const confirmEmoji = "✅"
+
+// confirmationsRequired returns true when new entries must be confirmed before they are exported.
+func (config Config) confirmationsRequired() bool {
+	return config.RequiredConfirmations > 1
+}
+
+// addConfirmation records a vote for a player entry. Each user can only confirm an entry once.
+func addConfirmation(ctx context.Context, db querier, steamID steamid.SteamID, author int64) error {
+	const query = `INSERT INTO player_confirmation (steamid, author, created_on) VALUES (?, ?, ?)`
+
+	if _, errExec := db.ExecContext(ctx, query, steamID.Int64(), author, time.Now().Unix()); errExec != nil {
+		return dbErr(errExec)
+	}
+
+	return nil
+}
+
+func countConfirmations(ctx context.Context, db querier, steamID steamid.SteamID) (int, error) {
+	var count int
+	if errCount := db.QueryRowContext(ctx, `SELECT count(*) FROM player_confirmation WHERE steamid = ?`,
+		steamID.Int64()).Scan(&count); errCount != nil {
+		return 0, errors.Join(errCount, errors.New("failed to count confirmations"))
+	}
+
+	return count, nil
+}
+
+func setAnnouncement(ctx context.Context, db querier, steamID steamid.SteamID, messageID string) error {
+	if _, errExec := db.ExecContext(ctx, `UPDATE player SET announcement_id = ? WHERE steamid = ?`,
+		messageID, steamID.Int64()); errExec != nil {
+		return errors.Join(errExec, errors.New("failed to update announcement"))
+	}
+
+	return nil
+}
+
+// getAnnouncedPlayer returns the steam id of the unconfirmed entry that the announcement message belongs to.
+func getAnnouncedPlayer(ctx context.Context, db querier, messageID string) (steamid.SteamID, error) {
+	const query = `SELECT steamid FROM player WHERE announcement_id = ? AND confirmed = 0 AND deleted_on = 0`
+
+	var sid int64
+	if errScan := db.QueryRowContext(ctx, query, messageID).Scan(&sid); errScan != nil {
+		return steamid.SteamID{}, dbErr(errScan)
+	}
+
+	return steamid.New(sid), nil
+}
+
+// confirmPlayer adds a confirmation vote to an unconfirmed entry, marking it as confirmed once the
+// required amount of distinct votes have been cast. The current vote count is returned.
+func confirmPlayer(ctx context.Context, database *sql.DB, steamID steamid.SteamID, author int64, required int) (int, error) {
+	var count int
+
+	errTx := withTx(ctx, database, func(tx *sql.Tx) error {
+		before, errBefore := getPlayer(ctx, tx, steamID)
+		if errBefore != nil {
+			return errBefore
+		}
+
+		if before.Confirmed {
+			return ErrAlreadyConfirmed
+		}
+
+		if errAdd := addConfirmation(ctx, tx, steamID, author); errAdd != nil {
+			return errAdd
+		}
+
+		var errCount error
+		if count, errCount = countConfirmations(ctx, tx, steamID); errCount != nil {
+			return errCount
+		}
+
+		if count < required {
+			return nil
+		}
+
+		if _, errExec := tx.ExecContext(ctx, `UPDATE player SET confirmed = 1 WHERE steamid = ?`, steamID.Int64()); errExec != nil {
+			return errors.Join(errExec, errors.New("failed to confirm player"))
+		}
+
+		after := before
+		after.Confirmed = true
+
+		return addAuditEntry(ctx, tx, auditConfirm, steamID, author, &before, &after)
+	})
+
+	return count, errTx
+}
+
+func confirmEntry(ctx context.Context, database *sql.DB, config Config, sid steamid.SteamID, author int64) (string, error) {
+	count, errConfirm := confirmPlayer(ctx, database, sid, author, config.RequiredConfirmations)
+	if errConfirm != nil {
+		switch {
+		case errors.Is(errConfirm, ErrNotFound):
+			return "", fmt.Errorf("steam id does not exist in database: %s", sid.String())
+		case errors.Is(errConfirm, ErrDuplicate):
+			return "", fmt.Errorf("you have already confirmed: %s", sid.String())
+		case errors.Is(errConfirm, ErrAlreadyConfirmed):
+			return "", fmt.Errorf("steam id is already confirmed: %s", sid.String())
+		}
+
+		return "", errConfirm
+	}
+
+	if count < config.RequiredConfirmations {
+		return fmt.Sprintf("Confirmation added for %s (%d/%d)", sid.String(), count, config.RequiredConfirmations), nil
+	}
+
+	return fmt.Sprintf("Entry confirmed and published: %s", sid.String()), nil
+}
+
+// announceUnconfirmed posts a message asking for confirmation of a new entry. Reacting to it with
+// confirmEmoji counts as a confirmation.
+func announceUnconfirmed(ctx context.Context, session *discordgo.Session, database *sql.DB, config Config,
+	channelID string, sid steamid.SteamID, author int64,
+) {
+	content := fmt.Sprintf("**%s** was added by <@%d> and requires %d confirmations before it is published.\n"+
+		"React with %s or use `!confirm %s` to confirm it.\n**Profile:** <https://steamcommunity.com/profiles/%s>",
+		sid.String(), author, config.RequiredConfirmations, confirmEmoji, sid.String(), sid.String())
+
+	message, errSend := session.ChannelMessageSend(channelID, content)
+	if errSend != nil {
+		slog.Error("Failed to send announcement", slog.String("error", errSend.Error()))
+
+		return
+	}
+
+	if errAnnouncement := setAnnouncement(ctx, database, sid, message.ID); errAnnouncement != nil {
+		slog.Error("Failed to save announcement", slog.String("error", errAnnouncement.Error()))
+
+		return
+	}
+
+	if errReact := session.MessageReactionAdd(channelID, message.ID, confirmEmoji); errReact != nil {
+		slog.Error("Failed to add reaction", slog.String("error", errReact.Error()))
+	}
+}
+
+// messageReactionAdd treats confirmEmoji reactions on announcements as confirmations from users that
+// are allowed to use the confirm command. Reactions on import previews confirm the import instead.
+func messageReactionAdd(ctx context.Context, database *sql.DB, config Config) func(*discordgo.Session, *discordgo.MessageReactionAdd) {
+	return func(session *discordgo.Session, reaction *discordgo.MessageReactionAdd) {
+		if reaction.UserID == session.State.User.ID || reaction.Emoji.Name != confirmEmoji {
+			return
+		}
+
+		pendingImport, errPending := hasPendingImport(ctx, database, reaction.MessageID)
+		if errPending != nil {
+			slog.Error("Failed to lookup pending import", slog.String("error", errPending.Error()))
+
+			return
+		}
+
+		if pendingImport {
+			handleImportReaction(ctx, session, database, config, reaction)
+
+			return
+		}
+
+		sid, errSid := getAnnouncedPlayer(ctx, database, reaction.MessageID)
+		if errSid != nil {
+			if !errors.Is(errSid, ErrNotFound) {
+				slog.Error("Failed to lookup announcement", slog.String("error", errSid.Error()))
+			}
+
+			return
+		}
+
+		if !config.GuildAllowed(reaction.GuildID) {
+			return
+		}
+
+		allowedRoles, public := config.RolesForCommand(reaction.GuildID, "confirm")
+		if !public {
+			allowed, errRoles := memberHasRole(session, reaction.GuildID, reaction.UserID, allowedRoles)
+			if errRoles != nil {
+				slog.Error("Failed to lookup role data", slog.String("error", errRoles.Error()))
+
+				return
+			}
+
+			if !allowed {
+				return
+			}
+		}
+
+		author, errAuthor := strconv.ParseInt(reaction.UserID, 10, 64)
+		if errAuthor != nil {
+			return
+		}
+
+		response, errConfirm := confirmEntry(ctx, database, config, sid, author)
+		if errConfirm != nil {
+			response = errConfirm.Error()
+		}
+
+		if _, errSend := session.ChannelMessageSend(reaction.ChannelID, fmt.Sprintf("<@%d> %s", author, response)); errSend != nil {
+			slog.Error("Failed to send message", slog.String("error", errSend.Error()))
+		}
+	}
+}
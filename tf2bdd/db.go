@@ -3,7 +3,6 @@
 import (
 	"context"
 	"database/sql"
-	"database/sql/driver"
 	"embed"
 	"errors"
 	"fmt"
@@ -32,6 +31,36 @@
 	ErrNotFound         = errors.New("entry not found")
 )
 
+// querier is implemented by both *sql.DB and *sql.Tx so that queries can be shared
+// between transactional and non-transactional paths.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// withTx runs fn inside a transaction, committing when no error is returned.
+func withTx(ctx context.Context, database *sql.DB, txFn func(tx *sql.Tx) error) error {
+	transaction, errBegin := database.BeginTx(ctx, nil)
+	if errBegin != nil {
+		return errors.Join(errBegin, errors.New("failed to start transaction"))
+	}
+
+	if errFn := txFn(transaction); errFn != nil {
+		if errRollback := transaction.Rollback(); errRollback != nil {
+			return errors.Join(errFn, errRollback)
+		}
+
+		return errFn
+	}
+
+	if errCommit := transaction.Commit(); errCommit != nil {
+		return errors.Join(errCommit, errors.New("failed to commit transaction"))
+	}
+
+	return nil
+}
+
 func dbErr(err error) error {
 	if err == nil {
 		return nil
@@ -71,6 +100,47 @@ func SetupDB(database *sql.DB) error {
 }
 
 func migrateDB(database *sql.DB) error {
+	return withMigrator(database, func(migrator *migrate.Migrate) error {
+		if errMigrate := migrator.Up(); errMigrate != nil && !errors.Is(errMigrate, migrate.ErrNoChange) {
+			return errors.Join(errMigrate, ErrPerformMigration)
+		}
+
+		return nil
+	})
+}
+
+// MigrateDown reverts the given amount of migrations.
+func MigrateDown(database *sql.DB, steps int) error {
+	return withMigrator(database, func(migrator *migrate.Migrate) error {
+		if errMigrate := migrator.Steps(-steps); errMigrate != nil && !errors.Is(errMigrate, migrate.ErrNoChange) {
+			return errors.Join(errMigrate, ErrPerformMigration)
+		}
+
+		return nil
+	})
+}
+
+// MigrationVersion returns the current schema version and whether a failed migration left it dirty.
+func MigrationVersion(database *sql.DB) (uint, bool, error) {
+	var (
+		version uint
+		dirty   bool
+	)
+
+	errVersion := withMigrator(database, func(migrator *migrate.Migrate) error {
+		var errVersion error
+		version, dirty, errVersion = migrator.Version()
+		if errVersion != nil && !errors.Is(errVersion, migrate.ErrNilVersion) {
+			return errors.Join(errVersion, errors.New("failed to read schema version"))
+		}
+
+		return nil
+	})
+
+	return version, dirty, errVersion
+}
+
+func withMigrator(database *sql.DB, migrateFn func(migrator *migrate.Migrate) error) error {
 	fsDriver, errIofs := iofs.New(migrations, "migrations")
 	if errIofs != nil {
 		return errors.Join(errIofs, ErrStoreIOFSOpen)
@@ -86,8 +156,8 @@ func migrateDB(database *sql.DB) error {
 		return errors.Join(errNewMigrator, ErrCreateMigration)
 	}
 
-	if errMigrate := migrator.Up(); errMigrate != nil && !errors.Is(errMigrate, migrate.ErrNoChange) {
-		return errors.Join(errMigrate, ErrPerformMigration)
+	if errMigrate := migrateFn(migrator); errMigrate != nil {
+		return errMigrate
 	}
 
 	// We do not call migrator.Close and instead close the fsDriver manually.
@@ -100,51 +170,66 @@ func migrateDB(database *sql.DB) error {
 	return nil
 }
 
-const proofSep = "^^"
-
-type Proof []string
-
-func (p *Proof) Scan(value interface{}) error {
-	strVal, ok := value.(string)
-	if !ok {
-		return errors.New("invalid type")
-	}
-	if strVal == "" {
-		*p = []string{}
-
-		return nil
-	}
-
-	*p = strings.Split(strVal, proofSep)
-
-	return nil
+func updatePlayer(ctx context.Context, database *sql.DB, player Player, author int64) error {
+	return withTx(ctx, database, func(tx *sql.Tx) error {
+		return savePlayer(ctx, tx, player, author)
+	})
 }
 
-func (p Proof) Value() (driver.Value, error) {
-	return strings.Join(p, proofSep), nil
-}
+// savePlayer updates the name, attributes and proof of an existing player. It should be called within
+// a transaction.
+func savePlayer(ctx context.Context, db querier, player Player, author int64) error {
+	before, errBefore := getPlayer(ctx, db, player.SteamID)
+	if errBefore != nil {
+		return errBefore
+	}
 
-func updatePlayer(ctx context.Context, database *sql.DB, player Player) error {
 	const query = `
 		UPDATE player 
-		SET attributes = ?,
-		    last_seen = ?,
+		SET last_seen = ?,
 		    last_name = ?,
-		    author = ?,
-		    proof = ?
+		    author = ?
 		WHERE steamid = ?`
 
-	if _, errExec := database.ExecContext(ctx, query, strings.Join(player.Attributes, ","), player.LastSeen.Time, player.LastSeen.PlayerName,
-		player.Author, player.Proof, player.SteamID.Int64()); errExec != nil {
+	if _, errExec := db.ExecContext(ctx, query, player.LastSeen.Time, player.LastSeen.PlayerName,
+		player.Author, player.SteamID.Int64()); errExec != nil {
 		return errExec
 	}
 
-	return nil
+	if errAttrs := setPlayerAttributes(ctx, db, player.SteamID, player.Attributes); errAttrs != nil {
+		return errAttrs
+	}
+
+	if errProof := setPlayerProof(ctx, db, player.SteamID, player.Proof, author); errProof != nil {
+		return errProof
+	}
+
+	if player.LastSeen.PlayerName != before.LastSeen.PlayerName {
+		if errName := observeName(ctx, db, player.SteamID, player.LastSeen.PlayerName, nameSourceEntry, 0); errName != nil {
+			return errName
+		}
+	}
+
+	after, errAfter := getPlayer(ctx, db, player.SteamID)
+	if errAfter != nil {
+		return errAfter
+	}
+
+	return addAuditEntry(ctx, db, auditUpdate, player.SteamID, author, &before, &after)
 }
 
-func getPlayer(ctx context.Context, database *sql.DB, steamID steamid.SteamID) (Player, error) {
-	const query = `SELECT steamid, attributes, last_seen, last_name, author, created_on, proof FROM player WHERE steamid = ?`
+// playerColumns selects the player row along with its attributes collapsed into a comma separated value,
+// in the order they were added.
+const playerColumns = `steamid,
+	coalesce((SELECT group_concat(attribute, ',')
+	          FROM (SELECT attribute FROM player_attribute pa WHERE pa.steamid = player.steamid ORDER BY pa.rowid)), ''),
+	last_seen, last_name, author, created_on, deleted_on, deleted_by, confirmed, guild_id, source, claimed_by`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
+func scanPlayer(row rowScanner) (Player, error) {
 	var (
 		player    Player
 		sid       int64
@@ -152,31 +237,128 @@ func getPlayer(ctx context.Context, database *sql.DB, steamID steamid.SteamID) (
 		lastSeen  int64
 		lastName  string
 		createdOn int64
-		proof     Proof
+		deletedOn int64
 	)
 
-	if errScan := database.
-		QueryRowContext(ctx, query, steamID.Int64()).
-		Scan(&sid, &attrs, &lastSeen, &lastName, &player.Author, &createdOn, &proof); errScan != nil {
-		return Player{}, dbErr(errScan)
+	if errScan := row.Scan(&sid, &attrs, &lastSeen, &lastName, &player.Author, &createdOn,
+		&deletedOn, &player.DeletedBy, &player.Confirmed, &player.GuildID, &player.Source, &player.ClaimedBy); errScan != nil {
+		return Player{}, errScan
 	}
 
 	player.CreatedOn = time.Unix(createdOn, 0)
 	player.SteamID = steamid.New(sid)
-	player.Attributes = strings.Split(strings.ToLower(attrs), ",")
+	player.Attributes = []string{}
+	if attrs != "" {
+		player.Attributes = strings.Split(attrs, ",")
+	}
 	player.LastSeen = LastSeen{
 		PlayerName: lastName,
 		Time:       lastSeen,
 	}
+	player.Proof = Proof{}
+
+	if deletedOn > 0 {
+		player.DeletedOn = time.Unix(deletedOn, 0)
+	}
+
+	return player, nil
+}
+
+// GetPlayer returns a player that has not been soft deleted.
+func GetPlayer(ctx context.Context, database *sql.DB, steamID steamid.SteamID) (Player, error) {
+	return getPlayer(ctx, database, steamID)
+}
+
+// getPlayer returns a player that has not been soft deleted.
+func getPlayer(ctx context.Context, database querier, steamID steamid.SteamID) (Player, error) {
+	const query = `SELECT ` + playerColumns + ` FROM player WHERE steamid = ? AND deleted_on = 0`
+
+	player, errScan := scanPlayer(database.QueryRowContext(ctx, query, steamID.Int64()))
+	if errScan != nil {
+		return Player{}, dbErr(errScan)
+	}
+
+	proof, errProof := getProof(ctx, database, steamID)
+	if errProof != nil {
+		return Player{}, errProof
+	}
+
 	player.Proof = proof
 
+	profile, errProfile := getSteamProfile(ctx, database, steamID)
+	if errProfile != nil {
+		return Player{}, errProfile
+	}
+
+	player.Steam = profile
+
 	return player, nil
 }
 
-func getPlayers(ctx context.Context, db *sql.DB) ([]Player, error) {
-	const query = `SELECT steamid, attributes, last_seen, last_name, author, created_on, proof FROM player`
+// getDeletedPlayer returns a player that has been soft deleted, but not yet purged.
+func getDeletedPlayer(ctx context.Context, database querier, steamID steamid.SteamID) (Player, error) {
+	const query = `SELECT ` + playerColumns + ` FROM player WHERE steamid = ? AND deleted_on > 0`
+
+	player, errScan := scanPlayer(database.QueryRowContext(ctx, query, steamID.Int64()))
+	if errScan != nil {
+		return Player{}, dbErr(errScan)
+	}
+
+	proof, errProof := getProof(ctx, database, steamID)
+	if errProof != nil {
+		return Player{}, errProof
+	}
+
+	player.Proof = proof
+
+	profile, errProfile := getSteamProfile(ctx, database, steamID)
+	if errProfile != nil {
+		return Player{}, errProfile
+	}
+
+	player.Steam = profile
+
+	return player, nil
+}
 
-	rows, err := db.QueryContext(ctx, query)
+// getPlayers returns all players that have not been soft deleted.
+func getPlayers(ctx context.Context, db querier) ([]Player, error) {
+	return queryPlayers(ctx, db, `SELECT `+playerColumns+` FROM player WHERE deleted_on = 0`)
+}
+
+// queryPlayers runs a query selecting playerColumns and attaches the proof and steam profile of each
+// player returned.
+func queryPlayers(ctx context.Context, db querier, query string, args ...any) ([]Player, error) {
+	players, errPlayers := scanPlayers(ctx, db, query, args...)
+	if errPlayers != nil {
+		return nil, errPlayers
+	}
+
+	// Proof is loaded only after the player rows are closed as a :memory: database cannot
+	// open a second connection.
+	proofs, errProofs := getAllProof(ctx, db)
+	if errProofs != nil {
+		return nil, errProofs
+	}
+
+	profiles, errProfiles := getAllSteamProfiles(ctx, db)
+	if errProfiles != nil {
+		return nil, errProfiles
+	}
+
+	for idx := range players {
+		if proof, found := proofs[players[idx].SteamID.Int64()]; found {
+			players[idx].Proof = proof
+		}
+
+		players[idx].Steam = profiles[players[idx].SteamID.Int64()]
+	}
+
+	return players, nil
+}
+
+func scanPlayers(ctx context.Context, db querier, query string, args ...any) ([]Player, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, errors.Join(err, errors.New("failed to load player"))
 	}
@@ -190,29 +372,11 @@ func getPlayers(ctx context.Context, db *sql.DB) ([]Player, error) {
 	var players []Player
 
 	for rows.Next() {
-		var (
-			player    Player
-			sid       int64
-			attrs     string
-			lastSeen  int64
-			lastName  string
-			createdOn int64
-			proof     Proof
-		)
-
-		if errScan := rows.Scan(&sid, &attrs, &lastSeen, &lastName, &player.Author, &createdOn, &proof); errScan != nil {
+		player, errScan := scanPlayer(rows)
+		if errScan != nil {
 			return nil, errors.Join(errScan, errors.New("error scanning player row"))
 		}
 
-		player.CreatedOn = time.Unix(createdOn, 0)
-		player.SteamID = steamid.New(sid)
-		player.Attributes = strings.Split(strings.ToLower(attrs), ",")
-		player.LastSeen = LastSeen{
-			PlayerName: lastName,
-			Time:       lastSeen,
-		}
-		player.Proof = proof
-
 		players = append(players, player)
 	}
 
@@ -223,31 +387,207 @@ func getPlayers(ctx context.Context, db *sql.DB) ([]Player, error) {
 	return players, nil
 }
 
+// setPlayerAttributes replaces the attributes of a player with the provided set.
+func setPlayerAttributes(ctx context.Context, db querier, steamID steamid.SteamID, attributes []string) error {
+	if _, errDelete := db.ExecContext(ctx, `DELETE FROM player_attribute WHERE steamid = ?`, steamID.Int64()); errDelete != nil {
+		return errors.Join(errDelete, errors.New("failed to clear player attributes"))
+	}
+
+	const query = `INSERT OR IGNORE INTO player_attribute (steamid, attribute) VALUES (?, ?)`
+
+	for _, attr := range attributes {
+		if _, errInsert := db.ExecContext(ctx, query, steamID.Int64(), strings.ToLower(attr)); errInsert != nil {
+			return errors.Join(errInsert, errors.New("failed to add player attribute"))
+		}
+	}
+
+	return nil
+}
+
+// AddPlayer creates a new, confirmed, player entry.
 func AddPlayer(ctx context.Context, db *sql.DB, player Player, author int64) error {
+	player.Author = author
+	player.Confirmed = true
+
+	return withTx(ctx, db, func(tx *sql.Tx) error {
+		return addPlayer(ctx, tx, player, author, auditAdd)
+	})
+}
+
+// addPlayer creates a new player entry. The actor is the user performing the change and is used
+// as the entries author unless one is already set on the player.
+func addPlayer(ctx context.Context, db querier, player Player, actor int64, action auditAction) error {
 	const query = `
-		INSERT INTO player (steamid, attributes, last_seen, last_name, author, created_on, proof)
-		VALUES(?, ?, ?, ?, ?, ?, ?)`
+		INSERT INTO player (steamid, last_seen, last_name, author, created_on, confirmed, guild_id, source)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if player.Author == 0 {
+		player.Author = actor
+	}
 
 	if _, err := db.ExecContext(ctx, query,
 		player.SteamID.Int64(),
-		strings.ToLower(strings.Join(player.Attributes, ",")),
 		player.LastSeen.Time,
 		player.LastSeen.PlayerName,
-		author,
-		int(time.Now().Unix()),
-		player.Proof); err != nil {
+		player.Author,
+		time.Now().Unix(),
+		player.Confirmed,
+		player.GuildID,
+		player.Source); err != nil {
 		return dbErr(err)
 	}
 
-	return nil
+	if errAttrs := setPlayerAttributes(ctx, db, player.SteamID, player.Attributes); errAttrs != nil {
+		return errAttrs
+	}
+
+	if errProof := setPlayerProof(ctx, db, player.SteamID, player.Proof, player.Author); errProof != nil {
+		return errProof
+	}
+
+	nameSource := nameSourceEntry
+	if player.Source != "" {
+		nameSource = upstreamLabel(player.Source)
+	} else if action == auditImport {
+		nameSource = nameSourceImport
+	}
+
+	if errName := observeName(ctx, db, player.SteamID, player.LastSeen.PlayerName, nameSource, player.LastSeen.Time); errName != nil {
+		return errName
+	}
+
+	after, errAfter := getPlayer(ctx, db, player.SteamID)
+	if errAfter != nil {
+		return errAfter
+	}
+
+	return addAuditEntry(ctx, db, action, player.SteamID, actor, nil, &after)
+}
+
+// DropPlayer soft deletes the player. The entry can be restored with restorePlayer until it is
+// permanently removed by purgeDeletedPlayers.
+func DropPlayer(ctx context.Context, db *sql.DB, steamID steamid.SteamID, author int64) error {
+	return withTx(ctx, db, func(tx *sql.Tx) error {
+		return dropPlayer(ctx, tx, steamID, author)
+	})
 }
 
-func dropPlayer(ctx context.Context, db *sql.DB, steamID steamid.SteamID) error {
-	const query = `DELETE FROM player WHERE steamid = ?`
+func dropPlayer(ctx context.Context, db querier, steamID steamid.SteamID, author int64) error {
+	before, errBefore := getPlayer(ctx, db, steamID)
+	if errBefore != nil {
+		return errBefore
+	}
 
-	if _, err := db.ExecContext(ctx, query, steamID.Int64()); err != nil {
+	const query = `UPDATE player SET deleted_on = ?, deleted_by = ? WHERE steamid = ?`
+
+	if _, err := db.ExecContext(ctx, query, time.Now().Unix(), author, steamID.Int64()); err != nil {
 		return errors.Join(err, errors.New("failed to drop user"))
 	}
 
-	return nil
+	return addAuditEntry(ctx, db, auditDelete, steamID, author, &before, nil)
+}
+
+// restorePlayer reverts a soft delete, leaving the entry otherwise unchanged.
+func restorePlayer(ctx context.Context, db *sql.DB, steamID steamid.SteamID, author int64) error {
+	return withTx(ctx, db, func(tx *sql.Tx) error {
+		return restoreDeletedPlayer(ctx, tx, steamID, author)
+	})
+}
+
+func restoreDeletedPlayer(ctx context.Context, db querier, steamID steamid.SteamID, author int64) error {
+	player, errPlayer := getDeletedPlayer(ctx, db, steamID)
+	if errPlayer != nil {
+		return errPlayer
+	}
+
+	const query = `UPDATE player SET deleted_on = 0, deleted_by = 0 WHERE steamid = ?`
+
+	if _, err := db.ExecContext(ctx, query, steamID.Int64()); err != nil {
+		return errors.Join(err, errors.New("failed to restore user"))
+	}
+
+	player.DeletedOn = time.Time{}
+	player.DeletedBy = 0
+
+	return addAuditEntry(ctx, db, auditRestore, steamID, author, nil, &player)
+}
+
+// purgeDeletedPlayers permanently removes players that were soft deleted before the cutoff time.
+func purgeDeletedPlayers(ctx context.Context, db *sql.DB, cutoff time.Time) (int, error) {
+	purged := 0
+
+	errTx := withTx(ctx, db, func(tx *sql.Tx) error {
+		const query = `SELECT ` + playerColumns + ` FROM player WHERE deleted_on > 0 AND deleted_on < ?`
+
+		expired, errExpired := queryPlayers(ctx, tx, query, cutoff.Unix())
+		if errExpired != nil {
+			return errors.Join(errExpired, errors.New("failed to load deleted players"))
+		}
+
+		for _, player := range expired {
+			if errAttrs := setPlayerAttributes(ctx, tx, player.SteamID, nil); errAttrs != nil {
+				return errAttrs
+			}
+
+			if _, errExec := tx.ExecContext(ctx, `DELETE FROM player_proof WHERE steamid = ?`, player.SteamID.Int64()); errExec != nil {
+				return errors.Join(errExec, errors.New("failed to purge user proof"))
+			}
+
+			if _, errExec := tx.ExecContext(ctx, `DELETE FROM player_confirmation WHERE steamid = ?`, player.SteamID.Int64()); errExec != nil {
+				return errors.Join(errExec, errors.New("failed to purge user confirmations"))
+			}
+
+			if _, errExec := tx.ExecContext(ctx, `DELETE FROM steam_profile WHERE steamid = ?`, player.SteamID.Int64()); errExec != nil {
+				return errors.Join(errExec, errors.New("failed to purge user steam profile"))
+			}
+
+			if _, errExec := tx.ExecContext(ctx, `DELETE FROM player_name_history WHERE steamid = ?`, player.SteamID.Int64()); errExec != nil {
+				return errors.Join(errExec, errors.New("failed to purge user name history"))
+			}
+
+			if errPurged := setPurgedSeq(ctx, tx, player.SteamID); errPurged != nil {
+				return errPurged
+			}
+
+			if _, errExec := tx.ExecContext(ctx, `DELETE FROM player WHERE steamid = ?`, player.SteamID.Int64()); errExec != nil {
+				return errors.Join(errExec, errors.New("failed to purge user"))
+			}
+
+			if errAudit := addAuditEntry(ctx, tx, auditPurge, player.SteamID, 0, &player, nil); errAudit != nil {
+				return errAudit
+			}
+		}
+
+		purged = len(expired)
+
+		return nil
+	})
+
+	return purged, errTx
+}
+
+// PurgeDeletedWorker periodically removes soft deleted players once they are older than the purgeAfter
+// duration. A zero duration disables purging.
+func PurgeDeletedWorker(ctx context.Context, db *sql.DB, purgeAfter time.Duration) {
+	if purgeAfter <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		purged, errPurge := purgeDeletedPlayers(ctx, db, time.Now().Add(-purgeAfter))
+		if errPurge != nil {
+			slog.Error("Failed to purge deleted players", slog.String("error", errPurge.Error()))
+		} else if purged > 0 {
+			slog.Info("Purged deleted players", slog.Int("count", purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
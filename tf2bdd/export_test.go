@@ -0,0 +1,78 @@
+package tf2bdd
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Unexported functions used by the tests of the tf2bdd_test package.
+var GetAuditLog = getAuditLog
+
+var SlashCommands = slashCommands
+
+// SlashCommandArgs returns the positional args an interaction is converted to.
+func SlashCommandArgs(interaction *discordgo.InteractionCreate, config Config) []string {
+	return slashCommandRequest(interaction, config).args
+}
+
+var (
+	AddEntry     = addEntry
+	CheckEntry   = checkEntry
+	ConfirmEntry = confirmEntry
+)
+
+var (
+	ParseStatus          = parseStatus
+	ReadLobbyAttachments = readLobbyAttachments
+)
+
+var SplitBulkArgs = splitBulkArgs
+
+// BulkEntries returns the reply of a bulk command.
+func BulkEntries(ctx context.Context, database *sql.DB, config Config, command string, steamIDs []string,
+	attributes []string, author int64,
+) (string, error) {
+	_, response, err := bulkEntries(ctx, database, config, command, steamIDs, attributes, config.KnownAttributes[0], "", author)
+
+	return response, err
+}
+
+var (
+	RestorePlayer       = restorePlayer
+	PurgeDeletedPlayers = purgeDeletedPlayers
+)
+
+var EditAttributes = editAttributes
+
+const (
+	AttributeSet    = attributeSet
+	AttributeAdd    = attributeAdd
+	AttributeRemove = attributeRemove
+)
+
+var (
+	RemoveProof = removeProof
+	EditProof   = editProof
+	MoveProof   = moveProof
+)
+
+var ProofWriteDeadline = proofWriteDeadline
+
+type PlayerReport = playerReport
+
+var (
+	AddReport    = addReport
+	ReviewReport = reviewReport
+)
+
+type (
+	LobbyOffer  = lobbyOffer
+	LobbyPlayer = lobbyPlayer
+)
+
+var (
+	AddLobbyOffer   = addLobbyOffer
+	AddLobbyPlayers = addLobbyPlayers
+)
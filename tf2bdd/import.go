@@ -0,0 +1,414 @@
+package tf2bdd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// pendingImportTTL is how long an import preview can be confirmed for.
+	pendingImportTTL = time.Hour
+	// importConflictLimit is the maximum number of attribute conflicts shown in an import preview.
+	importConflictLimit = 15
+)
+
+// ImportConflict is a player that is already present with different attributes than the imported list.
+type ImportConflict struct {
+	Player   Player
+	Existing []string
+}
+
+// ImportDiff describes the changes importing a player list would make. Only new players are added, all
+// other entries are left unchanged.
+type ImportDiff struct {
+	New       []Player
+	Present   []Player
+	Conflicts []ImportConflict
+	// Deleted players have an entry that was deleted, but not yet purged, and must be restored instead.
+	Deleted []Player
+	// Skipped counts the invalid, repeated or attribute-less players of the list.
+	Skipped int
+	// known holds every entry of the list, including repeats, for players that are already listed.
+	known []Player
+}
+
+// DiffImport compares the player list against the existing entries.
+func DiffImport(ctx context.Context, database *sql.DB, config Config, playerList PlayerListRoot) (ImportDiff, error) {
+	return diffImport(ctx, database, config, playerList)
+}
+
+func diffImport(ctx context.Context, db querier, config Config, playerList PlayerListRoot) (ImportDiff, error) {
+	var diff ImportDiff
+
+	existing, errExisting := scanPlayers(ctx, db, `SELECT `+playerColumns+` FROM player`)
+	if errExisting != nil {
+		return diff, errors.Join(errExisting, errors.New("failed to load existing entries for comparison"))
+	}
+
+	players := make(map[int64]Player, len(existing))
+	for _, player := range existing {
+		players[player.SteamID.Int64()] = player
+	}
+
+	seen := map[int64]bool{}
+
+	for _, player := range playerList.Players {
+		if !player.SteamID.Valid() {
+			diff.Skipped++
+
+			continue
+		}
+
+		current, found := players[player.SteamID.Int64()]
+		if found && current.DeletedOn.IsZero() {
+			diff.known = append(diff.known, player)
+		}
+
+		player.Attributes = filterAttributes(config.KnownAttributes, player.Attributes)
+		if len(player.Attributes) == 0 || seen[player.SteamID.Int64()] {
+			diff.Skipped++
+
+			continue
+		}
+
+		// Only entries with known attributes count, so a later repeat is used when the first has none.
+		seen[player.SteamID.Int64()] = true
+
+		switch {
+		case !found:
+			diff.New = append(diff.New, player)
+		case !current.DeletedOn.IsZero():
+			diff.Deleted = append(diff.Deleted, player)
+		case !sameAttributes(player.Attributes, current.Attributes):
+			diff.Conflicts = append(diff.Conflicts, ImportConflict{Player: player, Existing: current.Attributes})
+		default:
+			diff.Present = append(diff.Present, player)
+		}
+	}
+
+	return diff, nil
+}
+
+// applyImport adds the new players of the diff. Names of players that are already present are still recorded
+// in their name history. It should be called within the transaction the diff was created in.
+func applyImport(ctx context.Context, db querier, config Config, diff ImportDiff, guildID string, author int64) error {
+	for _, player := range diff.New {
+		// Imported entries go through confirmation the same way as entries added with !add.
+		player.Confirmed = !config.confirmationsRequired()
+		player.GuildID = config.entryGuild(guildID)
+
+		if errAdd := addPlayer(ctx, db, player, author, auditImport); errAdd != nil {
+			return errors.Join(errAdd, fmt.Errorf("failed to import player: %s", player.SteamID.String()))
+		}
+
+		if !player.Confirmed && author > 0 {
+			// The importing user counts as the first confirmation.
+			if errConfirm := addConfirmation(ctx, db, player.SteamID, author); errConfirm != nil {
+				return errConfirm
+			}
+		}
+	}
+
+	for _, player := range diff.known {
+		if errName := observeName(ctx, db, player.SteamID, player.LastSeen.PlayerName, nameSourceImport,
+			player.LastSeen.Time); errName != nil {
+			return errName
+		}
+	}
+
+	return nil
+}
+
+// ImportPlayerList adds the players of the list that do not already exist, returning the amount added. The
+// import is atomic, either every new player is added or none are.
+func ImportPlayerList(ctx context.Context, database *sql.DB, config Config, playerList PlayerListRoot, author int64) (int, error) {
+	diff, errImport := importPlayerList(ctx, database, config, playerList, "", author)
+
+	return len(diff.New), errImport
+}
+
+func importPlayerList(ctx context.Context, database *sql.DB, config Config, playerList PlayerListRoot, guildID string,
+	author int64,
+) (ImportDiff, error) {
+	var diff ImportDiff
+
+	errTx := withTx(ctx, database, func(tx *sql.Tx) error {
+		var errDiff error
+		if diff, errDiff = diffImport(ctx, tx, config, playerList); errDiff != nil {
+			return errDiff
+		}
+
+		return applyImport(ctx, tx, config, diff, guildID, author)
+	})
+	if errTx != nil {
+		return ImportDiff{}, errTx
+	}
+
+	return diff, nil
+}
+
+func downloadPlayerList(ctx context.Context, client *http.Client, url string) (PlayerListRoot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return PlayerListRoot{}, errors.Join(err, errors.New("failed to setup http request"))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return PlayerListRoot{}, errors.Join(err, errors.New("failed to download file"))
+	}
+
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			slog.Error("failed to close body", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	var playerList PlayerListRoot
+	if errDecode := json.NewDecoder(resp.Body).Decode(&playerList); errDecode != nil {
+		slog.Error("error decoding", slog.String("error", errDecode.Error()))
+
+		return PlayerListRoot{}, errors.Join(errDecode, errors.New("failed to decode file"))
+	}
+
+	return playerList, nil
+}
+
+// importSummary describes the result, or the expected result for a dry run, of an import.
+func importSummary(diff ImportDiff) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("**New:** %d\n", len(diff.New)))
+	builder.WriteString(fmt.Sprintf("**Already present:** %d\n", len(diff.Present)))
+	builder.WriteString(fmt.Sprintf("**Attribute conflicts:** %d, left unchanged\n", len(diff.Conflicts)))
+	for idx, conflict := range diff.Conflicts {
+		if idx == importConflictLimit {
+			builder.WriteString(fmt.Sprintf("... and %d more\n", len(diff.Conflicts)-idx))
+
+			break
+		}
+		builder.WriteString(fmt.Sprintf("`%s` imported: %s, listed: %s\n", conflict.Player.SteamID.String(),
+			strings.Join(conflict.Player.Attributes, ", "), strings.Join(conflict.Existing, ", ")))
+	}
+	if len(diff.Deleted) > 0 {
+		builder.WriteString(fmt.Sprintf("**Previously deleted:** %d, use !restore to bring them back\n", len(diff.Deleted)))
+	}
+	if diff.Skipped > 0 {
+		builder.WriteString(fmt.Sprintf("**Skipped:** %d invalid, repeated or without known attributes\n", diff.Skipped))
+	}
+
+	return builder.String()
+}
+
+func addPendingImport(ctx context.Context, db querier, messageID string, playerList PlayerListRoot, guildID string,
+	author int64,
+) error {
+	players, errMarshal := json.Marshal(playerList)
+	if errMarshal != nil {
+		return errors.Join(errMarshal, errors.New("failed to encode pending import"))
+	}
+
+	// Previews that can no longer be confirmed are removed whenever a new one is created.
+	if _, errExec := db.ExecContext(ctx, `DELETE FROM pending_import WHERE created_on < ?`,
+		time.Now().Add(-pendingImportTTL).Unix()); errExec != nil {
+		return errors.Join(errExec, errors.New("failed to remove expired imports"))
+	}
+
+	const query = `INSERT INTO pending_import (message_id, players, guild_id, author, created_on) VALUES (?, ?, ?, ?, ?)`
+
+	if _, errExec := db.ExecContext(ctx, query, messageID, string(players), guildID, author, time.Now().Unix()); errExec != nil {
+		return errors.Join(errExec, errors.New("failed to add pending import"))
+	}
+
+	return nil
+}
+
+// takePendingImport removes and returns the unexpired import previewed by the message, so that it can only
+// be confirmed once.
+func takePendingImport(ctx context.Context, db querier, messageID string) (PlayerListRoot, string, error) {
+	const query = `
+		DELETE FROM pending_import
+		WHERE message_id = ? AND created_on >= ?
+		RETURNING players, guild_id`
+
+	var (
+		players string
+		guildID string
+	)
+
+	if errScan := db.QueryRowContext(ctx, query, messageID, time.Now().Add(-pendingImportTTL).Unix()).
+		Scan(&players, &guildID); errScan != nil {
+		return PlayerListRoot{}, "", dbErr(errScan)
+	}
+
+	var playerList PlayerListRoot
+	if errUnmarshal := json.Unmarshal([]byte(players), &playerList); errUnmarshal != nil {
+		return PlayerListRoot{}, "", errors.Join(errUnmarshal, errors.New("failed to decode pending import"))
+	}
+
+	return playerList, guildID, nil
+}
+
+func hasPendingImport(ctx context.Context, db querier, messageID string) (bool, error) {
+	var found bool
+	if errScan := db.QueryRowContext(ctx, `SELECT count(*) > 0 FROM pending_import WHERE message_id = ?`,
+		messageID).Scan(&found); errScan != nil {
+		return false, errors.Join(errScan, errors.New("failed to load pending import"))
+	}
+
+	return found, nil
+}
+
+// confirmPendingImport imports the list previewed by the message. The list is compared against the entries
+// again, as they may have changed since the preview.
+func confirmPendingImport(ctx context.Context, database *sql.DB, config Config, messageID string, author int64) (ImportDiff, error) {
+	var diff ImportDiff
+
+	errTx := withTx(ctx, database, func(tx *sql.Tx) error {
+		playerList, guildID, errPending := takePendingImport(ctx, tx, messageID)
+		if errPending != nil {
+			return errPending
+		}
+
+		var errDiff error
+		if diff, errDiff = diffImport(ctx, tx, config, playerList); errDiff != nil {
+			return errDiff
+		}
+
+		return applyImport(ctx, tx, config, diff, guildID, author)
+	})
+
+	return diff, errTx
+}
+
+// importJSON imports the attached player lists in a single transaction. With the --dry-run option, a preview
+// of the changes is posted instead, which is imported once it is reacted to with confirmEmoji.
+func importJSON(ctx context.Context, session *discordgo.Session, database *sql.DB, config Config, req commandRequest,
+	author int64,
+) (string, error) {
+	dryRun := false
+
+	for _, arg := range req.args[1:] {
+		// Discord clients may replace the leading dashes with a dash punctuation character.
+		if strings.TrimLeft(arg, "-—") != "dry-run" {
+			return "", fmt.Errorf("unknown import option: %s", arg)
+		}
+
+		dryRun = true
+	}
+
+	if len(req.attachments) == 0 {
+		return "", errors.New("must attach json file to import")
+	}
+
+	client := &http.Client{}
+
+	importCtx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+
+	var playerList PlayerListRoot
+
+	for _, attach := range req.attachments {
+		attachedList, errLoad := downloadPlayerList(importCtx, client, attach.URL)
+		if errLoad != nil {
+			return "", errLoad
+		}
+
+		playerList.Players = append(playerList.Players, attachedList.Players...)
+	}
+
+	if !dryRun {
+		diff, errImport := importPlayerList(importCtx, database, config, playerList, req.guildID, author)
+		if errImport != nil {
+			return "", errImport
+		}
+
+		return fmt.Sprintf("Loaded %d new players\n%s", len(diff.New), importSummary(diff)), nil
+	}
+
+	diff, errDiff := DiffImport(importCtx, database, config, playerList)
+	if errDiff != nil {
+		return "", errDiff
+	}
+
+	if len(diff.New) == 0 {
+		return fmt.Sprintf("**Import preview**, there are no new players to import\n%s", importSummary(diff)), nil
+	}
+
+	content := fmt.Sprintf("**Import preview** by <@%d>\n%sReact with %s within %s to import the new players.",
+		author, importSummary(diff), confirmEmoji, pendingImportTTL.String())
+
+	message, errSend := session.ChannelMessageSend(req.channelID, content)
+	if errSend != nil {
+		return "", errors.Join(errSend, errors.New("failed to send import preview"))
+	}
+
+	if errPending := addPendingImport(ctx, database, message.ID, playerList, req.guildID, author); errPending != nil {
+		// Reactions to the preview would do nothing without the pending import.
+		if errDelete := session.ChannelMessageDelete(req.channelID, message.ID); errDelete != nil {
+			slog.Error("Failed to delete import preview", slog.String("error", errDelete.Error()))
+		}
+
+		return "", errPending
+	}
+
+	if errReact := session.MessageReactionAdd(req.channelID, message.ID, confirmEmoji); errReact != nil {
+		slog.Error("Failed to add reaction", slog.String("error", errReact.Error()))
+	}
+
+	return "Dry run complete, nothing has been imported yet.", nil
+}
+
+// handleImportReaction imports a previewed import when it is confirmed by a user allowed to use the import command.
+func handleImportReaction(ctx context.Context, session *discordgo.Session, database *sql.DB, config Config,
+	reaction *discordgo.MessageReactionAdd,
+) {
+	if !config.GuildAllowed(reaction.GuildID) {
+		return
+	}
+
+	allowedRoles, public := config.RolesForCommand(reaction.GuildID, "import")
+	if !public {
+		allowed, errRoles := memberHasRole(session, reaction.GuildID, reaction.UserID, allowedRoles)
+		if errRoles != nil {
+			slog.Error("Failed to lookup role data", slog.String("error", errRoles.Error()))
+
+			return
+		}
+
+		if !allowed {
+			return
+		}
+	}
+
+	author, errAuthor := strconv.ParseInt(reaction.UserID, 10, 64)
+	if errAuthor != nil {
+		return
+	}
+
+	var response string
+
+	diff, errImport := confirmPendingImport(ctx, database, config, reaction.MessageID, author)
+	switch {
+	case errors.Is(errImport, ErrNotFound):
+		response = "This import preview has expired or was already imported, run the import again"
+	case errImport != nil:
+		slog.Error("Failed to import players", slog.String("error", errImport.Error()))
+		response = "Failed to import players, nothing was imported"
+	default:
+		response = fmt.Sprintf("Loaded %d new players\n%s", len(diff.New), importSummary(diff))
+	}
+
+	if _, errSend := session.ChannelMessageSend(reaction.ChannelID, fmt.Sprintf("<@%d> %s", author, response)); errSend != nil {
+		slog.Error("Failed to send message", slog.String("error", errSend.Error()))
+	}
+}
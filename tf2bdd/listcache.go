@@ -0,0 +1,283 @@
+package tf2bdd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// supportedEncodings are the content encodings offered for lists, in order of preference.
+var supportedEncodings = []string{"zstd", "br", "gzip"}
+
+// dataVersion returns a value that changes whenever a player entry is changed, along with the time of
+// the change. Every mutation is recorded in the audit log, so its latest entry is used. This also
+// picks up changes made by other processes sharing the database, such as the command line tools.
+func dataVersion(ctx context.Context, db querier) (int64, time.Time, error) {
+	var (
+		version   int64
+		createdOn int64
+	)
+
+	if errScan := db.QueryRowContext(ctx, `SELECT audit_id, created_on FROM audit_log ORDER BY audit_id DESC LIMIT 1`).
+		Scan(&version, &createdOn); errScan != nil {
+		if errors.Is(errScan, sql.ErrNoRows) {
+			return 0, time.Time{}, nil
+		}
+
+		return 0, time.Time{}, errors.Join(errScan, errors.New("failed to load data version"))
+	}
+
+	return version, time.Unix(createdOn, 0), nil
+}
+
+// listVersion identifies the state of the data a list is built from.
+type listVersion struct {
+	players int64
+	// profiles changes whenever steam profiles are updated, which are not recorded in the audit log.
+	profiles int64
+}
+
+// currentListVersion returns the current version of the list data along with the time it last changed.
+func currentListVersion(ctx context.Context, db querier) (listVersion, time.Time, error) {
+	players, modified, errVersion := dataVersion(ctx, db)
+	if errVersion != nil {
+		return listVersion{}, time.Time{}, errVersion
+	}
+
+	profiles, enrichedOn, errEnriched := enrichmentVersion(ctx, db)
+	if errEnriched != nil {
+		return listVersion{}, time.Time{}, errEnriched
+	}
+
+	if enrichedOn.After(modified) {
+		modified = enrichedOn
+	}
+
+	return listVersion{players: players, profiles: profiles}, modified, nil
+}
+
+// listSnapshot is a serialized player list along with its compressed variants.
+type listSnapshot struct {
+	version  listVersion
+	modified time.Time
+	etag     string
+	// bodies holds the encoded list for each content encoding, the identity encoding uses an empty key.
+	bodies map[string][]byte
+}
+
+// listCache holds serialized snapshots of a list so that it is only queried and encoded again after
+// the players have been changed.
+type listCache struct {
+	database  *sql.DB
+	list      ListConfig
+	updateURL string
+
+	mu sync.Mutex
+	// snapshots are keyed by the filter applied to the list.
+	snapshots map[listFilter]*listSnapshot
+}
+
+func newListCache(database *sql.DB, list ListConfig, updateURL string) *listCache {
+	return &listCache{
+		database:  database,
+		list:      list,
+		updateURL: updateURL,
+		snapshots: map[listFilter]*listSnapshot{},
+	}
+}
+
+// snapshot returns the current snapshot of the list, rebuilding it if the players have changed since
+// it was created.
+func (cache *listCache) snapshot(ctx context.Context, filter listFilter) (*listSnapshot, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	version, modified, errVersion := currentListVersion(ctx, cache.database)
+	if errVersion != nil {
+		return nil, errVersion
+	}
+
+	current, found := cache.snapshots[filter]
+	if found && current.version == version {
+		return current, nil
+	}
+
+	players, errPlayers := getPlayers(ctx, cache.database)
+	if errPlayers != nil {
+		return nil, errPlayers
+	}
+
+	// Entries that predate the audit log have no recorded change time.
+	if modified.IsZero() {
+		modified = time.Unix(time.Now().Unix(), 0)
+	}
+
+	snapshot, errSnapshot := newListSnapshot(newPlayerList(players, cache.list, cache.updateURL, filter), version, modified)
+	if errSnapshot != nil {
+		return nil, errSnapshot
+	}
+
+	cache.snapshots[filter] = snapshot
+
+	return snapshot, nil
+}
+
+func newListSnapshot(list PlayerListRoot, version listVersion, modified time.Time) (*listSnapshot, error) {
+	var body bytes.Buffer
+	if errEncode := json.NewEncoder(&body).Encode(list); errEncode != nil {
+		return nil, errors.Join(errEncode, errors.New("failed to encode player list"))
+	}
+
+	hash := sha256.Sum256(body.Bytes())
+	snapshot := &listSnapshot{
+		version:  version,
+		modified: modified,
+		etag:     hex.EncodeToString(hash[:16]),
+		bodies:   map[string][]byte{"": body.Bytes()},
+	}
+
+	for _, encoding := range supportedEncodings {
+		compressed, errCompress := compress(encoding, body.Bytes())
+		if errCompress != nil {
+			return nil, errCompress
+		}
+
+		snapshot.bodies[encoding] = compressed
+	}
+
+	return snapshot, nil
+}
+
+func compress(encoding string, body []byte) ([]byte, error) {
+	var (
+		output bytes.Buffer
+		writer io.WriteCloser
+	)
+
+	switch encoding {
+	case "zstd":
+		encoder, errEncoder := zstd.NewWriter(nil)
+		if errEncoder != nil {
+			return nil, errors.Join(errEncoder, errors.New("failed to create zstd encoder"))
+		}
+
+		defer encoder.Close()
+
+		return encoder.EncodeAll(body, nil), nil
+	case "br":
+		writer = brotli.NewWriter(&output)
+	case "gzip":
+		writer = gzip.NewWriter(&output)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+
+	if _, errWrite := writer.Write(body); errWrite != nil {
+		return nil, errors.Join(errWrite, errors.New("failed to compress body"))
+	}
+
+	if errClose := writer.Close(); errClose != nil {
+		return nil, errors.Join(errClose, errors.New("failed to compress body"))
+	}
+
+	return output.Bytes(), nil
+}
+
+// negotiateEncoding selects the supported content encoding the client prefers according to its
+// Accept-Encoding header. An empty string is returned for the identity encoding.
+func negotiateEncoding(acceptEncoding string) string {
+	var (
+		selected  string
+		selectedQ float64
+		wildcardQ = -1.0
+		qualities = map[string]float64{}
+	)
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, errParse := strconv.ParseFloat(value, 64)
+			if errParse != nil {
+				continue
+			}
+
+			quality = parsed
+		}
+
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "*" {
+			wildcardQ = quality
+		} else {
+			qualities[name] = quality
+		}
+	}
+
+	for _, encoding := range supportedEncodings {
+		quality, found := qualities[encoding]
+		if !found {
+			quality = wildcardQ
+		}
+
+		if quality > selectedQ {
+			selected = encoding
+			selectedQ = quality
+		}
+	}
+
+	return selected
+}
+
+// serve writes the list using the encoding preferred by the client. Conditional requests using
+// If-None-Match or If-Modified-Since are answered with 304 Not Modified when the list is unchanged.
+func (snapshot *listSnapshot) serve(writer http.ResponseWriter, request *http.Request) {
+	encoding := negotiateEncoding(request.Header.Get("Accept-Encoding"))
+	etag := snapshot.etag
+
+	if encoding != "" {
+		etag += "-" + encoding
+		writer.Header().Set("Content-Encoding", encoding)
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Vary", "Accept-Encoding")
+	writer.Header().Set("ETag", strconv.Quote(etag))
+
+	http.ServeContent(writer, request, "", snapshot.modified, bytes.NewReader(snapshot.bodies[encoding]))
+}
+
+// handleGetPlayerList serves the players matching any of the lists attributes, or all players if it has none.
+func handleGetPlayerList(database *sql.DB, list ListConfig, updateURL string) http.HandlerFunc {
+	cache := newListCache(database, list, updateURL)
+
+	return func(writer http.ResponseWriter, request *http.Request) {
+		filter := parseListFilter(request)
+
+		snapshot, errSnapshot := cache.snapshot(request.Context(), filter)
+		if errSnapshot != nil {
+			slog.Error("Failed to load players", slog.String("error", errSnapshot.Error()))
+			writeError(writer, http.StatusInternalServerError, "Could not load player list")
+
+			return
+		}
+
+		snapshot.serve(writer, request)
+	}
+}
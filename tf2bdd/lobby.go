@@ -0,0 +1,441 @@
+package tf2bdd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/leighmacdonald/steamid/v4/steamid"
+)
+
+var ErrLobbyAdded = errors.New("lobby players have already been added")
+
+const (
+	// lobbyMaxPlayers limits the amount of players checked at once, TF2 servers hold at most 100 players.
+	lobbyMaxPlayers = 100
+	// lobbyMaxFileSize limits the size of attached status output.
+	lobbyMaxFileSize = 256 * 1024
+	// lobbyListLimit is the maximum number of players listed in a message, to stay within discords message size.
+	lobbyListLimit = 25
+	// lobbyOfferTTL is how long the players of a checked lobby can be added for.
+	lobbyOfferTTL = time.Hour
+)
+
+var (
+	// reStatusPlayer matches the steam ids of players in the output of the status console command, along with
+	// their name when it directly precedes the id, eg: `#    123 "name"    [U:1:22202]    05:12   67    0 active`.
+	reStatusPlayer = regexp.MustCompile(`(?:"([^"\n]*)"\s+)?\[U:1:(\d+)]`)
+	// reStatusLine matches a single player line, allowing quotes within the name.
+	reStatusLine = regexp.MustCompile(`(?:"(.*)"\s+)?\[U:1:(\d+)]`)
+)
+
+// lobbyPlayer is a player found in the status output.
+type lobbyPlayer struct {
+	SteamID steamid.SteamID `json:"steamid"`
+	Name    string          `json:"name"`
+}
+
+// parseStatus extracts the unique players from pasted status output. Lines that have been joined together,
+// as done by text commands, are also supported, though names containing quotes may then be cut short.
+func parseStatus(text string) []lobbyPlayer {
+	var players []lobbyPlayer
+
+	seen := map[int64]bool{}
+
+	for _, line := range strings.Split(text, "\n") {
+		matches := reStatusPlayer.FindAllStringSubmatch(line, -1)
+		if len(matches) == 1 {
+			matches = reStatusLine.FindAllStringSubmatch(line, 1)
+		}
+
+		for _, match := range matches {
+			sid := steamid.New("[U:1:" + match[2] + "]")
+			if !sid.Valid() || seen[sid.Int64()] {
+				continue
+			}
+
+			seen[sid.Int64()] = true
+			players = append(players, lobbyPlayer{SteamID: sid, Name: strings.TrimSpace(match[1])})
+		}
+	}
+
+	return players
+}
+
+// lobbyOffer holds the unlisted players of a checked lobby so they can be added using the button attached
+// to the offer message.
+type lobbyOffer struct {
+	LobbyID   int64
+	Players   []lobbyPlayer
+	Attribute string
+	GuildID   string
+	Author    int64
+	AddedBy   int64
+}
+
+func addLobbyOffer(ctx context.Context, db querier, offer *lobbyOffer) error {
+	players, errMarshal := json.Marshal(offer.Players)
+	if errMarshal != nil {
+		return errors.Join(errMarshal, errors.New("failed to encode lobby players"))
+	}
+
+	// Offers that can no longer be used are removed whenever a new one is created.
+	if _, errExec := db.ExecContext(ctx, `DELETE FROM lobby WHERE created_on < ?`,
+		time.Now().Add(-lobbyOfferTTL).Unix()); errExec != nil {
+		return errors.Join(errExec, errors.New("failed to remove expired lobbies"))
+	}
+
+	const query = `
+		INSERT INTO lobby (players, attribute, guild_id, author, created_on)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING lobby_id`
+
+	if errInsert := db.QueryRowContext(ctx, query, string(players), offer.Attribute, offer.GuildID, offer.Author,
+		time.Now().Unix()).Scan(&offer.LobbyID); errInsert != nil {
+		return errors.Join(errInsert, errors.New("failed to add lobby"))
+	}
+
+	return nil
+}
+
+func getLobbyOffer(ctx context.Context, db querier, lobbyID int64) (lobbyOffer, error) {
+	const query = `
+		SELECT lobby_id, players, attribute, guild_id, author, added_by
+		FROM lobby
+		WHERE lobby_id = ? AND created_on >= ?`
+
+	var (
+		offer   lobbyOffer
+		players string
+	)
+
+	if errScan := db.QueryRowContext(ctx, query, lobbyID, time.Now().Add(-lobbyOfferTTL).Unix()).
+		Scan(&offer.LobbyID, &players, &offer.Attribute, &offer.GuildID, &offer.Author, &offer.AddedBy); errScan != nil {
+		return lobbyOffer{}, dbErr(errScan)
+	}
+
+	if errUnmarshal := json.Unmarshal([]byte(players), &offer.Players); errUnmarshal != nil {
+		return lobbyOffer{}, errors.Join(errUnmarshal, errors.New("failed to decode lobby players"))
+	}
+
+	return offer, nil
+}
+
+// addLobbyPlayers adds the unlisted players of a lobby in a single transaction. Players that have been added,
+// or deleted, since the lobby was checked are skipped. The added steam ids are returned.
+func addLobbyPlayers(ctx context.Context, database *sql.DB, config Config, lobbyID int64, author int64) (lobbyOffer, []steamid.SteamID, error) {
+	var (
+		offer lobbyOffer
+		added []steamid.SteamID
+	)
+
+	errTx := withTx(ctx, database, func(tx *sql.Tx) error {
+		var errOffer error
+		if offer, errOffer = getLobbyOffer(ctx, tx, lobbyID); errOffer != nil {
+			return errOffer
+		}
+
+		if offer.AddedBy != 0 {
+			return ErrLobbyAdded
+		}
+
+		for _, lobbyPlayer := range offer.Players {
+			player := Player{
+				SteamID:    lobbyPlayer.SteamID,
+				Attributes: []string{offer.Attribute},
+				LastSeen:   LastSeen{PlayerName: lobbyPlayer.Name, Time: time.Now().Unix()},
+				Proof:      Proof{},
+				GuildID:    config.entryGuild(offer.GuildID),
+			}
+
+			status, errAdd := addIfUnlisted(ctx, tx, config, player, author)
+			if errAdd != nil {
+				return errAdd
+			}
+
+			if status == bulkAdded {
+				added = append(added, player.SteamID)
+			}
+		}
+
+		if _, errExec := tx.ExecContext(ctx, `UPDATE lobby SET added_by = ?, added_on = ? WHERE lobby_id = ?`,
+			author, time.Now().Unix(), lobbyID); errExec != nil {
+			return errors.Join(errExec, errors.New("failed to update lobby"))
+		}
+
+		return nil
+	})
+
+	return offer, added, errTx
+}
+
+// readLobbyAttachments returns the text of attached status output files.
+func readLobbyAttachments(ctx context.Context, attachments []*discordgo.MessageAttachment) (string, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var builder strings.Builder
+
+	for _, attachment := range attachments {
+		if attachment.Size > lobbyMaxFileSize {
+			return "", fmt.Errorf("%w: %s (%d > %d bytes)", ErrAttachmentSize, attachment.Filename, attachment.Size, lobbyMaxFileSize)
+		}
+
+		body, errRead := readLobbyAttachment(ctx, client, attachment.URL)
+		if errRead != nil {
+			return "", errRead
+		}
+
+		builder.Write(body)
+		builder.WriteString("\n")
+	}
+
+	return builder.String(), nil
+}
+
+func readLobbyAttachment(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if errReq != nil {
+		return nil, errors.Join(errReq, errors.New("failed to setup http request"))
+	}
+
+	resp, errResp := client.Do(req)
+	if errResp != nil {
+		return nil, errors.Join(errResp, errors.New("failed to download file"))
+	}
+
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			slog.Error("failed to close body", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	// Error pages would otherwise be checked as if they were status output.
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download attachment, invalid status code: %d", resp.StatusCode)
+	}
+
+	body, errRead := io.ReadAll(io.LimitReader(resp.Body, lobbyMaxFileSize))
+	if errRead != nil {
+		return nil, errors.Join(errRead, errors.New("failed to read file"))
+	}
+
+	return body, nil
+}
+
+// lobbyEntry checks every player of pasted or attached status output against the list. When some of them
+// are not listed, an offer to add them is posted with a button that adds them all at once.
+func lobbyEntry(ctx context.Context, session *discordgo.Session, database *sql.DB, config Config, req commandRequest,
+	author int64,
+) (string, error) {
+	attached, errAttached := readLobbyAttachments(ctx, req.attachments)
+	if errAttached != nil {
+		return "", errAttached
+	}
+
+	text := strings.Join(req.args[1:], " ")
+	if req.content != "" {
+		text = req.content
+	}
+
+	players := parseStatus(text + "\n" + attached)
+	if len(players) == 0 {
+		return "", errors.New("no steam ids found, paste the output of the status console command or attach it as a .txt file")
+	}
+
+	if len(players) > lobbyMaxPlayers {
+		return "", fmt.Errorf("too many players, at most %d can be checked at once", lobbyMaxPlayers)
+	}
+
+	var (
+		listed   []Player
+		unlisted []lobbyPlayer
+	)
+
+	for _, lobbyPlayer := range players {
+		player, errPlayer := getPlayer(ctx, database, lobbyPlayer.SteamID)
+		if errPlayer != nil {
+			if !errors.Is(errPlayer, ErrNotFound) {
+				return "", errPlayer
+			}
+
+			unlisted = append(unlisted, lobbyPlayer)
+
+			continue
+		}
+
+		// The name shown in the lobby is more useful than the one last recorded for the entry.
+		if lobbyPlayer.Name != "" {
+			player.LastSeen.PlayerName = lobbyPlayer.Name
+		}
+
+		listed = append(listed, player)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("**Lobby:** %d players, %d listed\n", len(players), len(listed)))
+	for idx, player := range listed {
+		if idx == lobbyListLimit {
+			builder.WriteString(fmt.Sprintf("... and %d more\n", len(listed)-idx))
+
+			break
+		}
+		builder.WriteString(fmt.Sprintf(":skull_crossbones: **%s** `%s` %s", player.LastSeen.PlayerName,
+			player.SteamID.Steam3(), strings.Join(player.Attributes, ", ")))
+		if !player.Confirmed {
+			builder.WriteString(" (unconfirmed)")
+		}
+		builder.WriteString("\n")
+	}
+
+	if len(unlisted) == 0 {
+		return builder.String(), nil
+	}
+
+	offer := lobbyOffer{
+		Players:   unlisted,
+		Attribute: config.defaultAttribute(req.channelID),
+		GuildID:   req.guildID,
+		Author:    author,
+	}
+
+	if errOffer := addLobbyOffer(ctx, database, &offer); errOffer != nil {
+		return "", errOffer
+	}
+
+	if _, errSend := session.ChannelMessageSendComplex(req.channelID, &discordgo.MessageSend{
+		Content:    lobbyOfferMessage(offer),
+		Components: lobbyComponents(offer.LobbyID, len(offer.Players)),
+	}); errSend != nil {
+		slog.Error("Failed to send lobby offer", slog.String("error", errSend.Error()))
+	}
+
+	return builder.String(), nil
+}
+
+func lobbyOfferMessage(offer lobbyOffer) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("**%d players are not listed:**\n", len(offer.Players)))
+	for idx, player := range offer.Players {
+		if idx == lobbyListLimit {
+			builder.WriteString(fmt.Sprintf("... and %d more\n", len(offer.Players)-idx))
+
+			break
+		}
+		builder.WriteString(fmt.Sprintf("`%s` %s\n", player.SteamID.Steam3(), player.Name))
+	}
+	builder.WriteString(fmt.Sprintf("They can all be added as `%s` using the button below within %s.", offer.Attribute,
+		lobbyOfferTTL.String()))
+
+	return builder.String()
+}
+
+const lobbyComponentPrefix = "lobby"
+
+func lobbyComponents(lobbyID int64, count int) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    fmt.Sprintf("Add %d unlisted", count),
+					Style:    discordgo.DangerButton,
+					CustomID: fmt.Sprintf("%s:add:%d", lobbyComponentPrefix, lobbyID),
+				},
+			},
+		},
+	}
+}
+
+// handleLobbyComponent adds the unlisted players of a lobby when its add button is pressed by a user allowed
+// to use the add command.
+func handleLobbyComponent(ctx context.Context, session *discordgo.Session, database *sql.DB, config Config,
+	interaction *discordgo.InteractionCreate, lobbyIDValue string,
+) {
+	lobbyID, errID := strconv.ParseInt(lobbyIDValue, 10, 64)
+	if errID != nil {
+		sendEphemeralMsg(session, interaction, "Invalid lobby id")
+
+		return
+	}
+
+	if interaction.Member == nil {
+		sendEphemeralMsg(session, interaction, "Players can only be added within a server")
+
+		return
+	}
+
+	if !config.GuildAllowed(interaction.GuildID) {
+		sendEphemeralMsg(session, interaction, "This server is not authorized to use the bot")
+
+		return
+	}
+
+	allowedRoles, public := config.RolesForCommand(interaction.GuildID, "add")
+	if !public {
+		allowed, errRoles := memberHasRole(session, interaction.GuildID, interaction.Member.User.ID, allowedRoles)
+		if errRoles != nil {
+			slog.Error("Failed to lookup role data", slog.String("error", errRoles.Error()))
+			sendEphemeralMsg(session, interaction, "Failed to lookup role data")
+
+			return
+		}
+
+		if !allowed {
+			sendEphemeralMsg(session, interaction, "Unauthorized")
+
+			return
+		}
+	}
+
+	author, errAuthor := strconv.ParseInt(interaction.Member.User.ID, 10, 64)
+	if errAuthor != nil {
+		sendEphemeralMsg(session, interaction, "Failed to get discord author id")
+
+		return
+	}
+
+	offer, added, errAdd := addLobbyPlayers(ctx, database, config, lobbyID, author)
+	if errAdd != nil {
+		switch {
+		case errors.Is(errAdd, ErrNotFound):
+			sendEphemeralMsg(session, interaction, "Lobby no longer exists")
+		case errors.Is(errAdd, ErrLobbyAdded):
+			sendEphemeralMsg(session, interaction, "These players have already been added")
+		default:
+			slog.Error("Failed to add lobby players", slog.String("error", errAdd.Error()))
+			sendEphemeralMsg(session, interaction, "Failed to add players")
+		}
+
+		return
+	}
+
+	content := fmt.Sprintf("%s\n**Added %d players** as `%s` by <@%d>", lobbyOfferMessage(offer), len(added),
+		offer.Attribute, author)
+	if skipped := len(offer.Players) - len(added); skipped > 0 {
+		content += fmt.Sprintf(", %d were added or deleted since the lobby was checked", skipped)
+	}
+
+	if config.confirmationsRequired() {
+		content += fmt.Sprintf("\nThe new entries require %d confirmations, use `!confirm` to confirm them.",
+			config.RequiredConfirmations)
+	}
+
+	if errRespond := session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: []discordgo.MessageComponent{},
+		},
+	}); errRespond != nil {
+		slog.Error("Failed to respond to interaction", slog.String("error", errRespond.Error()))
+	}
+}
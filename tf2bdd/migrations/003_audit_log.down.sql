@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS audit_log_steamid_idx;
+DROP TABLE IF EXISTS audit_log;
\ No newline at end of file
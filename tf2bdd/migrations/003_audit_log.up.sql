@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS audit_log
+(
+    audit_id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    steamid    BIGINT  NOT NULL,
+    action     TEXT    NOT NULL,
+    author     BIGINT  default 0,
+    created_on integer default 0,
+    before     TEXT    default '',
+    after      TEXT    default ''
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_steamid_idx ON audit_log (steamid);
@@ -0,0 +1,2 @@
+ALTER TABLE player DROP COLUMN deleted_by;
+ALTER TABLE player DROP COLUMN deleted_on;
\ No newline at end of file
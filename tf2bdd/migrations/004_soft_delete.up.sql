@@ -0,0 +1,2 @@
+ALTER TABLE player ADD COLUMN deleted_on integer default 0;
+ALTER TABLE player ADD COLUMN deleted_by BIGINT default 0;
@@ -0,0 +1,8 @@
+ALTER TABLE player ADD COLUMN attributes TEXT;
+
+UPDATE player
+SET attributes = coalesce((SELECT group_concat(attribute, ',')
+                           FROM player_attribute
+                           WHERE player_attribute.steamid = player.steamid), '');
+
+DROP TABLE IF EXISTS player_attribute;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS player_attribute
+(
+    steamid   BIGINT NOT NULL REFERENCES player (steamid),
+    attribute TEXT   NOT NULL,
+    PRIMARY KEY (steamid, attribute)
+);
+
+WITH RECURSIVE split(steamid, attribute, rest) AS (
+    SELECT steamid, '', lower(attributes) || ','
+    FROM player
+    UNION ALL
+    SELECT steamid, trim(substr(rest, 0, instr(rest, ','))), substr(rest, instr(rest, ',') + 1)
+    FROM split
+    WHERE rest <> '')
+INSERT OR IGNORE INTO player_attribute (steamid, attribute)
+SELECT steamid, attribute
+FROM split
+WHERE attribute <> '';
+
+ALTER TABLE player DROP COLUMN attributes;
@@ -0,0 +1 @@
+ALTER TABLE player DROP COLUMN proof_notes;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE player
+ADD COLUMN proof_notes TEXT default '';
\ No newline at end of file
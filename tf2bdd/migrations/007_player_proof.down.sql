@@ -0,0 +1,17 @@
+ALTER TABLE player ADD COLUMN proof TEXT default '';
+ALTER TABLE player ADD COLUMN proof_notes TEXT default '';
+
+UPDATE player
+SET proof       = coalesce((SELECT group_concat(value, '^^')
+                            FROM (SELECT value
+                                  FROM player_proof
+                                  WHERE player_proof.steamid = player.steamid
+                                  ORDER BY position)), ''),
+    proof_notes = coalesce((SELECT group_concat(note, '^^')
+                            FROM (SELECT note
+                                  FROM player_proof
+                                  WHERE player_proof.steamid = player.steamid
+                                  ORDER BY position)), '');
+
+DROP INDEX IF EXISTS player_proof_steamid_idx;
+DROP TABLE IF EXISTS player_proof;
@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS player_proof
+(
+    proof_id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    steamid    BIGINT  NOT NULL REFERENCES player (steamid),
+    position   INTEGER NOT NULL default 0,
+    value      TEXT    NOT NULL,
+    note       TEXT    NOT NULL default '',
+    kind       TEXT    NOT NULL default 'text',
+    author     BIGINT  NOT NULL default 0,
+    created_on integer NOT NULL default 0
+);
+
+CREATE INDEX IF NOT EXISTS player_proof_steamid_idx ON player_proof (steamid);
+
+WITH RECURSIVE split(steamid, position, value, note, rest, note_rest) AS (
+    SELECT steamid, -1, '', '', proof || '^^', coalesce(proof_notes, '') || '^^'
+    FROM player
+    WHERE coalesce(proof, '') <> ''
+    UNION ALL
+    SELECT steamid,
+           position + 1,
+           substr(rest, 1, instr(rest, '^^') - 1),
+           CASE WHEN instr(note_rest, '^^') > 0 THEN substr(note_rest, 1, instr(note_rest, '^^') - 1) ELSE '' END,
+           substr(rest, instr(rest, '^^') + 2),
+           CASE WHEN instr(note_rest, '^^') > 0 THEN substr(note_rest, instr(note_rest, '^^') + 2) ELSE '' END
+    FROM split
+    WHERE rest <> '')
+INSERT INTO player_proof (steamid, position, value, note, kind, author, created_on)
+SELECT split.steamid,
+       split.position,
+       split.value,
+       split.note,
+       CASE WHEN split.value LIKE 'http://%' OR split.value LIKE 'https://%' THEN 'url' ELSE 'text' END,
+       coalesce(player.author, 0),
+       coalesce(player.created_on, 0)
+FROM split
+         INNER JOIN player ON player.steamid = split.steamid
+WHERE split.position >= 0
+ORDER BY split.steamid, split.position;
+
+ALTER TABLE player DROP COLUMN proof_notes;
+ALTER TABLE player DROP COLUMN proof;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS player_report_steamid_idx;
+DROP TABLE IF EXISTS player_report;
\ No newline at end of file
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS player_report
+(
+    report_id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    steamid     BIGINT  NOT NULL,
+    attributes  TEXT    default '',
+    proof       TEXT    default '',
+    note        TEXT    default '',
+    author      BIGINT  default 0,
+    created_on  integer default 0,
+    status      TEXT    default 'pending',
+    reviewer    BIGINT  default 0,
+    reviewed_on integer default 0,
+    message_id  TEXT    default ''
+);
+
+CREATE INDEX IF NOT EXISTS player_report_steamid_idx ON player_report (steamid);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS player_confirmation;
+ALTER TABLE player DROP COLUMN announcement_id;
+ALTER TABLE player DROP COLUMN confirmed;
@@ -0,0 +1,10 @@
+ALTER TABLE player ADD COLUMN confirmed BOOLEAN default 1;
+ALTER TABLE player ADD COLUMN announcement_id TEXT default '';
+
+CREATE TABLE IF NOT EXISTS player_confirmation
+(
+    steamid    BIGINT  NOT NULL,
+    author     BIGINT  NOT NULL,
+    created_on integer default 0,
+    PRIMARY KEY (steamid, author)
+);
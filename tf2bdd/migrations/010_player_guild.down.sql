@@ -0,0 +1 @@
+ALTER TABLE player DROP COLUMN guild_id;
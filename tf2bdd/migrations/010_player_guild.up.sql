@@ -0,0 +1 @@
+ALTER TABLE player ADD COLUMN guild_id TEXT default '';
@@ -0,0 +1,2 @@
+ALTER TABLE audit_log DROP COLUMN author_label;
+DROP TABLE IF EXISTS api_token;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS api_token
+(
+    token_id     INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash   TEXT    NOT NULL UNIQUE,
+    scope        TEXT    NOT NULL,
+    author_label TEXT    NOT NULL,
+    created_on   integer default 0,
+    revoked_on   integer default 0
+);
+
+ALTER TABLE audit_log ADD COLUMN author_label TEXT default '';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS sync_state;
+DROP INDEX IF EXISTS player_change_seq_idx;
+ALTER TABLE player DROP COLUMN added_seq;
+ALTER TABLE player DROP COLUMN change_seq;
@@ -0,0 +1,14 @@
+ALTER TABLE player ADD COLUMN change_seq INTEGER default 0;
+ALTER TABLE player ADD COLUMN added_seq INTEGER default 0;
+
+UPDATE player
+SET change_seq = coalesce((SELECT max(audit_id) FROM audit_log WHERE audit_log.steamid = player.steamid), 0);
+
+CREATE INDEX IF NOT EXISTS player_change_seq_idx ON player (change_seq);
+
+CREATE TABLE IF NOT EXISTS sync_state
+(
+    purged_seq INTEGER default 0
+);
+
+INSERT INTO sync_state (purged_seq) VALUES (0);
@@ -0,0 +1,2 @@
+ALTER TABLE player DROP COLUMN claimed_by;
+ALTER TABLE player DROP COLUMN source;
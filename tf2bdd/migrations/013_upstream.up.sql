@@ -0,0 +1,2 @@
+ALTER TABLE player ADD COLUMN source TEXT default '';
+ALTER TABLE player ADD COLUMN claimed_by BIGINT default 0;
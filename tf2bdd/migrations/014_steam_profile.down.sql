@@ -0,0 +1,3 @@
+ALTER TABLE sync_state DROP COLUMN enriched_on;
+ALTER TABLE sync_state DROP COLUMN enriched_seq;
+DROP TABLE IF EXISTS steam_profile;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS steam_profile
+(
+    steamid      BIGINT PRIMARY KEY,
+    persona_name TEXT    default '',
+    avatar       TEXT    default '',
+    visibility   TEXT    default '',
+    time_created integer default 0,
+    vac_bans     integer default 0,
+    game_bans    integer default 0,
+    updated_on   integer default 0
+);
+
+ALTER TABLE sync_state ADD COLUMN enriched_seq INTEGER default 0;
+ALTER TABLE sync_state ADD COLUMN enriched_on INTEGER default 0;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS player_name_history
+(
+    steamid    BIGINT  NOT NULL,
+    name       TEXT    NOT NULL,
+    source     TEXT    default '',
+    first_seen integer default 0,
+    last_seen  integer default 0,
+    PRIMARY KEY (steamid, name)
+);
+
+INSERT OR IGNORE INTO player_name_history (steamid, name, source, first_seen, last_seen)
+SELECT steamid, last_name, 'entry', coalesce(nullif(last_seen, 0), created_on), coalesce(nullif(last_seen, 0), created_on)
+FROM player
+WHERE last_name != '';
+
+INSERT OR IGNORE INTO player_name_history (steamid, name, source, first_seen, last_seen)
+SELECT steamid, persona_name, 'steam', updated_on, updated_on
+FROM steam_profile
+WHERE persona_name != '';
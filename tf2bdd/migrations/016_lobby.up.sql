@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS lobby
+(
+    lobby_id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    players    TEXT    default '',
+    attribute  TEXT    default '',
+    guild_id   TEXT    default '',
+    author     BIGINT  default 0,
+    created_on integer default 0,
+    added_by   BIGINT  default 0,
+    added_on   integer default 0
+);
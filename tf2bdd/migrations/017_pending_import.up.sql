@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS pending_import
+(
+    message_id TEXT PRIMARY KEY,
+    players    TEXT    default '',
+    guild_id   TEXT    default '',
+    author     BIGINT  default 0,
+    created_on integer default 0
+);
@@ -0,0 +1,153 @@
+package tf2bdd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leighmacdonald/steamid/v4/steamid"
+)
+
+// Sources recorded with observed names. Names observed from an upstream list use the upstreams author label.
+const (
+	nameSourceEntry  = "entry"
+	nameSourceImport = "import"
+	nameSourceSteam  = "steam"
+)
+
+// nameHistoryLimit is the maximum number of names shown by the names command.
+const nameHistoryLimit = 25
+
+// NameHistoryEntry is a name a player has been observed using.
+type NameHistoryEntry struct {
+	Name string `json:"name"`
+	// Source is where the name was first observed.
+	Source    string    `json:"source"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// observeName records a name used by a player. Names already in their history only have their first and
+// last seen times extended.
+func observeName(ctx context.Context, db querier, steamID steamid.SteamID, name string, source string, seenOn int64) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+
+	if seenOn <= 0 {
+		seenOn = time.Now().Unix()
+	}
+
+	const query = `
+		INSERT INTO player_name_history (steamid, name, source, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (steamid, name) DO UPDATE
+		SET first_seen = min(first_seen, excluded.first_seen),
+		    last_seen = max(last_seen, excluded.last_seen)`
+
+	if _, errExec := db.ExecContext(ctx, query, steamID.Int64(), name, source, seenOn, seenOn); errExec != nil {
+		return errors.Join(errExec, errors.New("failed to record player name"))
+	}
+
+	return nil
+}
+
+// getNameHistory returns the names a player has been observed using, most recently seen first. A limit
+// of 0 returns every name.
+func getNameHistory(ctx context.Context, db querier, steamID steamid.SteamID, limit int) ([]NameHistoryEntry, error) {
+	const query = `
+		SELECT name, source, first_seen, last_seen
+		FROM player_name_history
+		WHERE steamid = ?
+		ORDER BY last_seen DESC, name
+		LIMIT ?`
+
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, errQuery := db.QueryContext(ctx, query, steamID.Int64(), limit)
+	if errQuery != nil {
+		return nil, errors.Join(errQuery, errors.New("failed to load name history"))
+	}
+
+	defer func() {
+		if errClose := rows.Close(); errClose != nil {
+			slog.Error("Failed to close rows handle", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	names := []NameHistoryEntry{}
+
+	for rows.Next() {
+		var (
+			entry     NameHistoryEntry
+			firstSeen int64
+			lastSeen  int64
+		)
+
+		if errScan := rows.Scan(&entry.Name, &entry.Source, &firstSeen, &lastSeen); errScan != nil {
+			return nil, errors.Join(errScan, errors.New("error scanning name history row"))
+		}
+
+		entry.FirstSeen = time.Unix(firstSeen, 0)
+		entry.LastSeen = time.Unix(lastSeen, 0)
+		names = append(names, entry)
+	}
+
+	if rows.Err() != nil {
+		return nil, errors.Join(rows.Err(), errors.New("error reading name history rows"))
+	}
+
+	return names, nil
+}
+
+func playerNames(ctx context.Context, database *sql.DB, sid steamid.SteamID) (string, error) {
+	names, errNames := getNameHistory(ctx, database, sid, nameHistoryLimit)
+	if errNames != nil {
+		return "", errNames
+	}
+
+	if len(names) == 0 {
+		return "", fmt.Errorf("no names recorded for steam id: %s", sid.String())
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("**Names for:** %s\n", sid.String()))
+	for _, entry := range names {
+		builder.WriteString(fmt.Sprintf("`%s` - `%s` **%s** (%s)\n", entry.FirstSeen.Format(time.DateOnly),
+			entry.LastSeen.Format(time.DateOnly), entry.Name, entry.Source))
+	}
+
+	return builder.String(), nil
+}
+
+func handleGetPlayerNames(database *sql.DB) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		sid, valid := pathSteamID(writer, request)
+		if !valid {
+			return
+		}
+
+		if _, errPlayer := getPlayer(request.Context(), database, sid); errPlayer != nil {
+			writeEntryError(writer, errPlayer)
+
+			return
+		}
+
+		names, errNames := getNameHistory(request.Context(), database, sid, 0)
+		if errNames != nil {
+			writeEntryError(writer, errNames)
+
+			return
+		}
+
+		writeJSON(writer, http.StatusOK, names)
+	}
+}
@@ -0,0 +1,206 @@
+package tf2bdd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/leighmacdonald/steamid/v4/steamid"
+)
+
+var ErrDuplicateProof = errors.New("duplicate proof provided")
+
+type ProofKind string
+
+const (
+	ProofKindURL        ProofKind = "url"
+	ProofKindText       ProofKind = "text"
+	ProofKindAttachment ProofKind = "attachment"
+)
+
+// ProofEntry is a single item of proof with an optional note describing what it shows.
+type ProofEntry struct {
+	ProofID   int64     `json:"proof_id"`
+	Value     string    `json:"value"`
+	Note      string    `json:"note"`
+	Kind      ProofKind `json:"kind"`
+	Author    int64     `json:"author"`
+	CreatedOn time.Time `json:"created_on"`
+}
+
+// MarshalJSON encodes the entry as a plain string value to remain compatible with the playerlist schema.
+func (p ProofEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Value)
+}
+
+func (p *ProofEntry) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &p.Value)
+}
+
+type Proof []ProofEntry
+
+// proofKind determines the kind of proof for a user provided value.
+func proofKind(value string) ProofKind {
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+		return ProofKindURL
+	}
+
+	return ProofKindText
+}
+
+// parseProofInput splits user input in the form of "value | note" into a proof entry.
+func parseProofInput(input string) ProofEntry {
+	value, note, _ := strings.Cut(input, "|")
+	value = strings.TrimSpace(value)
+
+	return ProofEntry{Value: value, Note: strings.TrimSpace(note), Kind: proofKind(value)}
+}
+
+const proofColumns = `proof_id, steamid, value, note, kind, author, created_on`
+
+func scanProof(row rowScanner) (int64, ProofEntry, error) {
+	var (
+		proof     ProofEntry
+		sid       int64
+		createdOn int64
+	)
+
+	if errScan := row.Scan(&proof.ProofID, &sid, &proof.Value, &proof.Note, &proof.Kind, &proof.Author, &createdOn); errScan != nil {
+		return 0, ProofEntry{}, errors.Join(errScan, errors.New("error scanning proof row"))
+	}
+
+	proof.CreatedOn = time.Unix(createdOn, 0)
+
+	return sid, proof, nil
+}
+
+// getProof returns the proof of a single player.
+func getProof(ctx context.Context, db querier, steamID steamid.SteamID) (Proof, error) {
+	const query = `SELECT ` + proofColumns + ` FROM player_proof WHERE steamid = ? ORDER BY position, proof_id`
+
+	proofs, errProofs := queryProof(ctx, db, query, steamID.Int64())
+	if errProofs != nil {
+		return nil, errProofs
+	}
+
+	if proof, found := proofs[steamID.Int64()]; found {
+		return proof, nil
+	}
+
+	return Proof{}, nil
+}
+
+// getAllProof returns the proof of every player, keyed by steam id.
+func getAllProof(ctx context.Context, db querier) (map[int64]Proof, error) {
+	const query = `SELECT ` + proofColumns + ` FROM player_proof ORDER BY steamid, position, proof_id`
+
+	return queryProof(ctx, db, query)
+}
+
+func queryProof(ctx context.Context, db querier, query string, args ...any) (map[int64]Proof, error) {
+	rows, errQuery := db.QueryContext(ctx, query, args...)
+	if errQuery != nil {
+		return nil, errors.Join(errQuery, errors.New("failed to load proof"))
+	}
+
+	defer func() {
+		if errClose := rows.Close(); errClose != nil {
+			slog.Error("Failed to close rows handle", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	proofs := map[int64]Proof{}
+
+	for rows.Next() {
+		sid, proof, errScan := scanProof(rows)
+		if errScan != nil {
+			return nil, errScan
+		}
+
+		proofs[sid] = append(proofs[sid], proof)
+	}
+
+	if rows.Err() != nil {
+		return nil, errors.Join(rows.Err(), errors.New("error reading proof rows"))
+	}
+
+	return proofs, nil
+}
+
+// setPlayerProof stores the proof of a player in the order provided. Existing entries, identified by
+// their ProofID, are updated in place, new entries are created with the author provided and any entries
+// no longer present are removed.
+func setPlayerProof(ctx context.Context, db querier, steamID steamid.SteamID, proof Proof, author int64) error {
+	existing, errExisting := getProof(ctx, db, steamID)
+	if errExisting != nil {
+		return errExisting
+	}
+
+	keep := map[int64]bool{}
+	for _, entry := range proof {
+		if entry.ProofID > 0 {
+			keep[entry.ProofID] = true
+		}
+	}
+
+	for _, entry := range existing {
+		if keep[entry.ProofID] {
+			continue
+		}
+
+		if _, errDelete := db.ExecContext(ctx, `DELETE FROM player_proof WHERE proof_id = ?`, entry.ProofID); errDelete != nil {
+			return errors.Join(errDelete, errors.New("failed to remove proof"))
+		}
+	}
+
+	const (
+		updateQuery = `UPDATE player_proof SET position = ?, value = ?, note = ?, kind = ? WHERE proof_id = ? AND steamid = ?`
+		insertQuery = `
+		INSERT INTO player_proof (steamid, position, value, note, kind, author, created_on)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	)
+
+	for position, entry := range proof {
+		if entry.Kind == "" {
+			entry.Kind = proofKind(entry.Value)
+		}
+
+		if entry.ProofID > 0 {
+			if _, errUpdate := db.ExecContext(ctx, updateQuery, position, entry.Value, entry.Note, entry.Kind,
+				entry.ProofID, steamID.Int64()); errUpdate != nil {
+				return errors.Join(errUpdate, errors.New("failed to update proof"))
+			}
+
+			continue
+		}
+
+		if _, errInsert := db.ExecContext(ctx, insertQuery, steamID.Int64(), position, entry.Value, entry.Note,
+			entry.Kind, author, time.Now().Unix()); errInsert != nil {
+			return errors.Join(errInsert, errors.New("failed to add proof"))
+		}
+	}
+
+	return nil
+}
+
+// appendProof adds new entries to the end of the players existing proof, rejecting any that already exist.
+func appendProof(ctx context.Context, database *sql.DB, player Player, newProof Proof, author int64) error {
+	for _, entry := range newProof {
+		if slices.ContainsFunc(player.Proof, func(existing ProofEntry) bool { return existing.Value == entry.Value }) {
+			return ErrDuplicateProof
+		}
+
+		player.Proof = append(player.Proof, entry)
+	}
+
+	if errUpdate := updatePlayer(ctx, database, player, author); errUpdate != nil {
+		return errors.Join(errUpdate, errors.New("could not update player entry"))
+	}
+
+	return nil
+}
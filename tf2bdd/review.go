@@ -0,0 +1,392 @@
+package tf2bdd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/leighmacdonald/steamid/v4/steamid"
+)
+
+var ErrReportReviewed = errors.New("report has already been reviewed")
+
+type reportStatus string
+
+const (
+	reportPending  reportStatus = "pending"
+	reportApproved reportStatus = "approved"
+	reportRejected reportStatus = "rejected"
+	// reportDuplicate is used for approved reports of players that already have an entry, which may have
+	// been deleted.
+	reportDuplicate reportStatus = "duplicate"
+)
+
+// reviewPermission is the permission name, used in command_roles, for approving and rejecting reports.
+const reviewPermission = "review"
+
+// playerReport is a submission from a user without permission to add entries directly. It must be approved
+// before it becomes a player entry.
+type playerReport struct {
+	ReportID   int64
+	SteamID    steamid.SteamID
+	Attributes []string
+	Proof      ProofEntry
+	Author     int64
+	CreatedOn  time.Time
+	Status     reportStatus
+	Reviewer   int64
+	ReviewedOn time.Time
+	MessageID  string
+}
+
+func addReport(ctx context.Context, db querier, report *playerReport) error {
+	const query = `
+		INSERT INTO player_report (steamid, attributes, proof, note, author, created_on, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING report_id`
+
+	report.CreatedOn = time.Unix(time.Now().Unix(), 0)
+	report.Status = reportPending
+
+	if errInsert := db.QueryRowContext(ctx, query, report.SteamID.Int64(), strings.Join(report.Attributes, ","),
+		report.Proof.Value, report.Proof.Note, report.Author, report.CreatedOn.Unix(), report.Status).
+		Scan(&report.ReportID); errInsert != nil {
+		return errors.Join(errInsert, errors.New("failed to add report"))
+	}
+
+	return nil
+}
+
+func removeReport(ctx context.Context, db querier, reportID int64) error {
+	if _, errExec := db.ExecContext(ctx, `DELETE FROM player_report WHERE report_id = ?`, reportID); errExec != nil {
+		return errors.Join(errExec, errors.New("failed to remove report"))
+	}
+
+	return nil
+}
+
+func setReportMessage(ctx context.Context, db querier, reportID int64, messageID string) error {
+	if _, errExec := db.ExecContext(ctx, `UPDATE player_report SET message_id = ? WHERE report_id = ?`,
+		messageID, reportID); errExec != nil {
+		return errors.Join(errExec, errors.New("failed to update report"))
+	}
+
+	return nil
+}
+
+const reportColumns = `report_id, steamid, attributes, proof, note, author, created_on, status, reviewer, reviewed_on, message_id`
+
+func scanReport(row rowScanner) (playerReport, error) {
+	var (
+		report     playerReport
+		sid        int64
+		attrs      string
+		createdOn  int64
+		reviewedOn int64
+	)
+
+	if errScan := row.Scan(&report.ReportID, &sid, &attrs, &report.Proof.Value, &report.Proof.Note, &report.Author,
+		&createdOn, &report.Status, &report.Reviewer, &reviewedOn, &report.MessageID); errScan != nil {
+		return playerReport{}, dbErr(errScan)
+	}
+
+	report.SteamID = steamid.New(sid)
+	report.Attributes = strings.Split(attrs, ",")
+	report.Proof.Kind = proofKind(report.Proof.Value)
+	report.CreatedOn = time.Unix(createdOn, 0)
+
+	if reviewedOn > 0 {
+		report.ReviewedOn = time.Unix(reviewedOn, 0)
+	}
+
+	return report, nil
+}
+
+func getReport(ctx context.Context, db querier, reportID int64) (playerReport, error) {
+	const query = `SELECT ` + reportColumns + ` FROM player_report WHERE report_id = ?`
+
+	return scanReport(db.QueryRowContext(ctx, query, reportID))
+}
+
+func getPendingReport(ctx context.Context, db querier, steamID steamid.SteamID) (playerReport, error) {
+	const query = `SELECT ` + reportColumns + ` FROM player_report WHERE steamid = ? AND status = ?`
+
+	return scanReport(db.QueryRowContext(ctx, query, steamID.Int64(), reportPending))
+}
+
+// reviewReport approves or rejects a pending report. Approved reports are added as player entries, with
+// the reporter as the author and tagged with the reviewers guild. When confirmations are required, the
+// approval counts as the first one.
+func reviewReport(ctx context.Context, database *sql.DB, config Config, guildID string, reportID int64, reviewer int64,
+	approve bool,
+) (playerReport, error) {
+	var report playerReport
+
+	errTx := withTx(ctx, database, func(tx *sql.Tx) error {
+		var errReport error
+		report, errReport = getReport(ctx, tx, reportID)
+		if errReport != nil {
+			return errReport
+		}
+
+		if report.Status != reportPending {
+			return ErrReportReviewed
+		}
+
+		report.Status = reportRejected
+		if approve {
+			var errApprove error
+			if report.Status, errApprove = approveReport(ctx, tx, config, report, guildID, reviewer); errApprove != nil {
+				return errApprove
+			}
+		}
+
+		report.Reviewer = reviewer
+		report.ReviewedOn = time.Unix(time.Now().Unix(), 0)
+
+		const query = `UPDATE player_report SET status = ?, reviewer = ?, reviewed_on = ? WHERE report_id = ?`
+
+		if _, errExec := tx.ExecContext(ctx, query, report.Status, report.Reviewer, report.ReviewedOn.Unix(),
+			report.ReportID); errExec != nil {
+			return errors.Join(errExec, errors.New("failed to update report"))
+		}
+
+		return nil
+	})
+
+	return report, errTx
+}
+
+// approveReport adds the reported player. Reports of players that already have an entry, including
+// deleted ones, are resolved as duplicates rather than left pending, as they could never be approved.
+func approveReport(ctx context.Context, tx *sql.Tx, config Config, report playerReport, guildID string, reviewer int64) (reportStatus, error) {
+	var deletedOn int64
+
+	errExisting := tx.QueryRowContext(ctx, `SELECT deleted_on FROM player WHERE steamid = ?`,
+		report.SteamID.Int64()).Scan(&deletedOn)
+	switch {
+	case errExisting == nil:
+		return reportDuplicate, nil
+	case !errors.Is(errExisting, sql.ErrNoRows):
+		return "", errors.Join(errExisting, errors.New("failed to check for existing player"))
+	}
+
+	player := Player{
+		SteamID:    report.SteamID,
+		Attributes: report.Attributes,
+		LastSeen:   LastSeen{Time: report.CreatedOn.Unix()},
+		Author:     report.Author,
+		Proof:      Proof{report.Proof},
+		Confirmed:  !config.confirmationsRequired(),
+		GuildID:    config.entryGuild(guildID),
+	}
+
+	if errAdd := addPlayer(ctx, tx, player, reviewer, auditApprove); errAdd != nil {
+		return "", errAdd
+	}
+
+	if !player.Confirmed {
+		if errConfirm := addConfirmation(ctx, tx, player.SteamID, reviewer); errConfirm != nil {
+			return "", errConfirm
+		}
+	}
+
+	return reportApproved, nil
+}
+
+// reportEntry submits a player to the review queue. The args are in the form of: [attributes...] <proof> [| note].
+func reportEntry(ctx context.Context, session *discordgo.Session, database *sql.DB, config Config, sid steamid.SteamID,
+	args []string, defaultAttr string, author int64,
+) (string, error) {
+	if config.ReviewChannelID == "" {
+		return "", errors.New("reports are not enabled")
+	}
+
+	var attrs []string
+	for len(args) > 0 && slices.Contains(config.KnownAttributes, strings.ToLower(args[0])) {
+		attrs = append(attrs, strings.ToLower(args[0]))
+		args = args[1:]
+	}
+
+	attrs, errAttrs := NormalizeAttributes(config.KnownAttributes, attrs)
+	if errAttrs != nil {
+		return "", errAttrs
+	}
+
+	if len(attrs) == 0 {
+		attrs = append(attrs, defaultAttr)
+	}
+
+	proof := parseProofInput(trimInputString(strings.Join(args, " ")))
+	if proof.Value == "" {
+		return "", errors.New("reports must include proof")
+	}
+
+	if _, errPlayer := getPlayer(ctx, database, sid); errPlayer == nil {
+		return "", fmt.Errorf("steam id is already listed: %s", sid.String())
+	}
+
+	if _, errPending := getPendingReport(ctx, database, sid); errPending == nil {
+		return "", fmt.Errorf("a report for this steam id is already pending review: %s", sid.String())
+	}
+
+	report := playerReport{
+		SteamID:    sid,
+		Attributes: attrs,
+		Proof:      proof,
+		Author:     author,
+	}
+
+	if errAdd := addReport(ctx, database, &report); errAdd != nil {
+		return "", errAdd
+	}
+
+	message, errSend := session.ChannelMessageSendComplex(config.ReviewChannelID, &discordgo.MessageSend{
+		Content:    reportMessage(report),
+		Components: reviewComponents(report.ReportID),
+	})
+	if errSend != nil {
+		// Without a review message the report could never be reviewed, and would block any new reports.
+		if errRemove := removeReport(ctx, database, report.ReportID); errRemove != nil {
+			slog.Error("Failed to remove unposted report", slog.String("error", errRemove.Error()))
+		}
+
+		return "", errors.Join(errSend, errors.New("failed to post report for review"))
+	}
+
+	if errMessage := setReportMessage(ctx, database, report.ReportID, message.ID); errMessage != nil {
+		return "", errMessage
+	}
+
+	return fmt.Sprintf("Report #%d submitted for review: %s", report.ReportID, sid.String()), nil
+}
+
+func reportMessage(report playerReport) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("**Report #%d** by <@%d>\n", report.ReportID, report.Author))
+	builder.WriteString(fmt.Sprintf("**SteamID:** %s\n", report.SteamID.String()))
+	builder.WriteString(fmt.Sprintf("**Attributes:** %s\n", strings.Join(report.Attributes, ", ")))
+
+	if report.Proof.Kind == ProofKindURL {
+		builder.WriteString(fmt.Sprintf("**Proof:** <%s>", report.Proof.Value))
+	} else {
+		builder.WriteString(fmt.Sprintf("**Proof:** %s", report.Proof.Value))
+	}
+
+	if report.Proof.Note != "" {
+		builder.WriteString(fmt.Sprintf(" - %s", report.Proof.Note))
+	}
+
+	builder.WriteString(fmt.Sprintf("\n**Profile:** <https://steamcommunity.com/profiles/%s>", report.SteamID.String()))
+
+	return builder.String()
+}
+
+const reportComponentPrefix = "report"
+
+func reviewComponents(reportID int64) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Approve",
+					Style:    discordgo.SuccessButton,
+					CustomID: fmt.Sprintf("%s:approve:%d", reportComponentPrefix, reportID),
+				},
+				discordgo.Button{
+					Label:    "Reject",
+					Style:    discordgo.DangerButton,
+					CustomID: fmt.Sprintf("%s:reject:%d", reportComponentPrefix, reportID),
+				},
+			},
+		},
+	}
+}
+
+// handleReviewComponent processes the approve and reject buttons attached to reports in the review channel.
+func handleReviewComponent(ctx context.Context, session *discordgo.Session, database *sql.DB, config Config,
+	interaction *discordgo.InteractionCreate, action string, reportIDValue string,
+) {
+	reportID, errID := strconv.ParseInt(reportIDValue, 10, 64)
+	if errID != nil {
+		sendEphemeralMsg(session, interaction, "Invalid report id")
+
+		return
+	}
+
+	if interaction.Member == nil {
+		sendEphemeralMsg(session, interaction, "Reports can only be reviewed within a server")
+
+		return
+	}
+
+	if !config.GuildAllowed(interaction.GuildID) {
+		sendEphemeralMsg(session, interaction, "This server is not authorized to use the bot")
+
+		return
+	}
+
+	allowedRoles, public := config.RolesForCommand(interaction.GuildID, reviewPermission)
+	if !public {
+		allowed, errRoles := memberHasRole(session, interaction.GuildID, interaction.Member.User.ID, allowedRoles)
+		if errRoles != nil {
+			slog.Error("Failed to lookup role data", slog.String("error", errRoles.Error()))
+			sendEphemeralMsg(session, interaction, "Failed to lookup role data")
+
+			return
+		}
+
+		if !allowed {
+			sendEphemeralMsg(session, interaction, "Unauthorized")
+
+			return
+		}
+	}
+
+	reviewer, errReviewer := strconv.ParseInt(interaction.Member.User.ID, 10, 64)
+	if errReviewer != nil {
+		sendEphemeralMsg(session, interaction, "Failed to get discord author id")
+
+		return
+	}
+
+	report, errReview := reviewReport(ctx, database, config, interaction.GuildID, reportID, reviewer,
+		action == "approve")
+	if errReview != nil {
+		sendEphemeralMsg(session, interaction, errReview.Error())
+
+		return
+	}
+
+	content := fmt.Sprintf("%s\n**Report %s** by <@%d>", reportMessage(report), report.Status, reviewer)
+
+	if report.Status == reportDuplicate {
+		if _, errDeleted := getDeletedPlayer(ctx, database, report.SteamID); errDeleted == nil {
+			content += fmt.Sprintf(", the entry was previously deleted, use `!restore %s` to bring it back",
+				report.SteamID.String())
+		} else {
+			content += ", the steam id is already listed"
+		}
+	}
+
+	if errRespond := session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: []discordgo.MessageComponent{},
+		},
+	}); errRespond != nil {
+		slog.Error("Failed to respond to interaction", slog.String("error", errRespond.Error()))
+	}
+
+	if report.Status == reportApproved && config.confirmationsRequired() {
+		announceUnconfirmed(ctx, session, database, config, interaction.ChannelID, report.SteamID, reviewer)
+	}
+}
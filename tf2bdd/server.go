@@ -1,8 +1,9 @@
 package tf2bdd
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -39,70 +40,251 @@ type Player struct {
 	LastSeen   LastSeen        `json:"last_seen,omitempty"`
 	Author     int64           `json:"-"`
 	CreatedOn  time.Time       `json:"-"`
+	DeletedOn  time.Time       `json:"-"`
+	DeletedBy  int64           `json:"-"`
+	Confirmed  bool            `json:"-"`
+	GuildID    string          `json:"-"`
+	Source     string          `json:"-"`
+	ClaimedBy  int64           `json:"-"`
 	Proof      Proof           `json:"proof"`
+	Steam      *SteamProfile   `json:"steam,omitempty"`
 }
 
+// listFilter controls which entries are served in addition to the list's own criteria.
+type listFilter struct {
+	// includeUnconfirmed includes entries awaiting confirmation.
+	includeUnconfirmed bool
+	// excludeUpstream leaves out the entries merged from upstream lists.
+	excludeUpstream bool
+}
+
+// parseListFilter reads the filter from the query parameters. Entries awaiting confirmation are only
+// included when explicitly requested with unconfirmed=true, upstream entries are included unless
+// upstream=false is set.
+func parseListFilter(request *http.Request) listFilter {
+	query := request.URL.Query()
+
+	return listFilter{
+		includeUnconfirmed: query.Get("unconfirmed") == "true",
+		excludeUpstream:    query.Get("upstream") == "false",
+	}
+}
+
+// newPlayerList builds the list document containing the players that match the list.
+func newPlayerList(players []Player, list ListConfig, updateURL string, filter listFilter) PlayerListRoot {
+	results := PlayerListRoot{
+		ListSource: ListSource{
+			Authors:     list.Authors,
+			Description: list.Description,
+			Title:       list.Title,
+			UpdateURL:   updateURL,
+		},
+		Schema:  schemaURL,
+		Players: []Player{},
+	}
+
+	for _, player := range players {
+		if list.includes(player, filter) {
+			results.Players = append(results.Players, player)
+		}
+	}
+
+	return results
+}
+
+// includes returns true if the player is part of the list.
+func (list ListConfig) includes(player Player, filter listFilter) bool {
+	if !player.Confirmed && !filter.includeUnconfirmed {
+		return false
+	}
+
+	if player.Source != "" && filter.excludeUpstream {
+		return false
+	}
+
+	if list.GuildID != "" && player.GuildID != list.GuildID {
+		return false
+	}
+
+	if len(list.Attributes) == 0 {
+		return true
+	}
+
+	for _, attr := range list.Attributes {
+		if slices.Contains(player.Attributes, attr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// defaultList returns the list configured by the top level list_* options.
+func (config Config) defaultList() ListConfig {
+	return ListConfig{
+		Title:       config.ListTitle,
+		Description: config.ListDescription,
+		Authors:     config.ListAuthors,
+		Attributes:  config.ExportedAttrs,
+	}
+}
+
+// ExportPlayerList returns the default list, with the same contents as served by /v1/steamids. Entries
+// merged from upstream lists are left out unless includeUpstream is set.
+func ExportPlayerList(ctx context.Context, database *sql.DB, config Config, includeUpstream bool) (PlayerListRoot, error) {
+	updateURL, errUpdateURL := config.UpdateURL()
+	if errUpdateURL != nil {
+		return PlayerListRoot{}, errUpdateURL
+	}
+
+	players, errPlayers := getPlayers(ctx, database)
+	if errPlayers != nil {
+		return PlayerListRoot{}, errPlayers
+	}
+
+	return newPlayerList(players, config.defaultList(), updateURL, listFilter{excludeUpstream: !includeUpstream}), nil
+}
+
+// handleGetSteamIDs serves the default list, configured by the top level list_* options.
 func handleGetSteamIDs(database *sql.DB, config Config) http.HandlerFunc {
 	updateURL, errUpdateURL := config.UpdateURL()
 	if errUpdateURL != nil {
 		panic(fmt.Errorf("failed to create valid update url: %w", errUpdateURL))
 	}
 
+	return handleGetPlayerList(database, config.defaultList(), updateURL)
+}
+
+// maxLookupSize is the maximum amount of steam ids that can be checked in a single lookup request, which
+// is enough to cover a full server.
+const maxLookupSize = 100
+
+// lookupPlayer returns the player if they are part of the default list.
+func lookupPlayer(ctx context.Context, database *sql.DB, config Config, sid steamid.SteamID, filter listFilter) (Player, error) {
+	player, errPlayer := getPlayer(ctx, database, sid)
+	if errPlayer != nil {
+		return Player{}, errPlayer
+	}
+
+	if !config.defaultList().includes(player, filter) {
+		return Player{}, ErrNotFound
+	}
+
+	return player, nil
+}
+
+// handleGetSteamID checks if a single player, in any non-vanity steam id format, is part of the default list.
+func handleGetSteamID(database *sql.DB, config Config) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		writer.Header().Set("Content-Type", "application/json")
-
-		results := PlayerListRoot{
-			ListSource: ListSource{
-				Authors:     config.ListAuthors,
-				Description: config.ListDescription,
-				Title:       config.ListTitle,
-				UpdateURL:   updateURL,
-			},
-			Schema:  schemaURL,
-			Players: []Player{},
+		sid, valid := pathSteamID(writer, request)
+		if !valid {
+			return
 		}
 
-		players, errPlayers := getPlayers(request.Context(), database)
-		if errPlayers != nil {
-			slog.Error("Failed to load players", slog.String("error", errPlayers.Error()))
-			writer.WriteHeader(http.StatusInternalServerError)
-			if errEncode := json.NewEncoder(writer).Encode(map[string]string{
-				"error": "Could not load player list",
-			}); errEncode != nil {
-				slog.Error("failed to encode response", slog.String("error", errEncode.Error()))
+		filter := parseListFilter(request)
+
+		player, errPlayer := lookupPlayer(request.Context(), database, config, sid, filter)
+		if errPlayer != nil {
+			if errors.Is(errPlayer, ErrNotFound) {
+				writeError(writer, http.StatusNotFound, "steam id is not listed")
+
+				return
 			}
 
+			slog.Error("Failed to load player", slog.String("error", errPlayer.Error()))
+			writeError(writer, http.StatusInternalServerError, "Could not load player")
+
+			return
+		}
+
+		writeJSON(writer, http.StatusOK, &player)
+	}
+}
+
+// handleLookupSteamIDs checks which of the steam ids in the request body, a json array, are part of the
+// default list. Only the players that are listed are returned.
+func handleLookupSteamIDs(database *sql.DB, config Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var ids []string
+		if !decodeRequest(writer, request, &ids) {
+			return
+		}
+
+		if len(ids) > maxLookupSize {
+			writeError(writer, http.StatusBadRequest, fmt.Sprintf("too many steam ids, maximum is %d", maxLookupSize))
+
 			return
 		}
 
-		if len(config.ExportedAttrs) > 0 {
-			var filtered []Player
+		filter := parseListFilter(request)
+		players := []Player{}
+
+		for _, id := range ids {
+			sid := steamid.New(id)
+			if !sid.Valid() {
+				writeError(writer, http.StatusBadRequest, fmt.Sprintf("invalid steam id: %s", id))
 
-			for _, player := range players {
-				for _, attr := range config.ExportedAttrs {
-					if slices.Contains(player.Attributes, attr) {
-						filtered = append(filtered, player)
+				return
+			}
+
+			if slices.ContainsFunc(players, func(player Player) bool { return player.SteamID.Int64() == sid.Int64() }) {
+				continue
+			}
 
-						break
-					}
+			player, errPlayer := lookupPlayer(request.Context(), database, config, sid, filter)
+			if errPlayer != nil {
+				if errors.Is(errPlayer, ErrNotFound) {
+					continue
 				}
+
+				slog.Error("Failed to load player", slog.String("error", errPlayer.Error()))
+				writeError(writer, http.StatusInternalServerError, "Could not load players")
+
+				return
 			}
-			results.Players = filtered
-		} else {
-			results.Players = players
+
+			players = append(players, player)
 		}
 
-		writer.WriteHeader(http.StatusOK)
+		writeJSON(writer, http.StatusOK, players)
+	}
+}
+
+// handleGetList serves one of the additional lists defined in the lists config block.
+func handleGetList(database *sql.DB, config Config, list ListConfig) http.HandlerFunc {
+	updateURL, errUpdateURL := config.ListURL(list.Name)
+	if errUpdateURL != nil {
+		panic(fmt.Errorf("failed to create valid update url: %w", errUpdateURL))
+	}
 
-		if errEncode := json.NewEncoder(writer).Encode(results); errEncode != nil {
-			slog.Error("failed to encode response", slog.String("error", errEncode.Error()))
-		}
+	if len(list.Authors) == 0 {
+		list.Authors = config.ListAuthors
 	}
+
+	return handleGetPlayerList(database, list, updateURL)
 }
 
 func CreateRouter(database *sql.DB, config Config) *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /v1/steamids", handleGetSteamIDs(database, config))
+	mux.HandleFunc("GET /v1/steamids/{steamid}", handleGetSteamID(database, config))
+	mux.HandleFunc("POST /v1/steamids/lookup", handleLookupSteamIDs(database, config))
+	mux.HandleFunc("GET /v1/changes", handleGetChanges(database, config))
+	mux.HandleFunc("GET /v1/proof/{hash}", handleGetProof(config))
+	mux.HandleFunc("GET /v1/players/{steamid}", requireToken(database, ScopeRead, handleGetPlayer(database)))
+	mux.HandleFunc("GET /v1/players/{steamid}/names", requireToken(database, ScopeRead, handleGetPlayerNames(database)))
+
+	// Read-only mirrors do not accept changes to the list.
+	if config.Mode != ModeServe {
+		mux.HandleFunc("POST /v1/players", requireToken(database, ScopeWrite, handleCreatePlayer(database, config)))
+		mux.HandleFunc("PATCH /v1/players/{steamid}", requireToken(database, ScopeWrite, handleUpdatePlayer(database, config)))
+		mux.HandleFunc("DELETE /v1/players/{steamid}", requireToken(database, ScopeAdmin, handleDeletePlayer(database)))
+		mux.HandleFunc("POST /v1/players/{steamid}/proof", requireToken(database, ScopeWrite, handleAddProof(database)))
+	}
+
+	for _, list := range config.Lists {
+		mux.HandleFunc("GET /v1/lists/"+list.Name, handleGetList(database, config, list))
+	}
 
 	return mux
 }
@@ -112,7 +294,7 @@ func CreateHTTPServer(mux *http.ServeMux, listenAddr string) *http.Server {
 		Addr:           listenAddr,
 		Handler:        mux,
 		ReadTimeout:    5 * time.Second,
-		WriteTimeout:   5 * time.Second,
+		WriteTimeout:   proofWriteTimeout,
 		MaxHeaderBytes: 1 << 20,
 	}
 }
@@ -1,13 +1,19 @@
 package tf2bdd_test
 
 import (
+	"compress/gzip"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/leighmacdonald/steamid/v4/steamid"
 	"github.com/leighmacdonald/tf2bdd/tf2bdd"
@@ -76,6 +82,1099 @@ func TestHandleGetSteamIDS(t *testing.T) {
 	require.Equal(t, len(localPlayers), len(players.Players))
 }
 
+func TestHandleGetSteamIDSCache(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	require.NoError(t, tf2bdd.AddPlayer(ctx, database, tf2bdd.Player{
+		SteamID:    steamid.New(76561198237337976),
+		Attributes: []string{"cheater"},
+	}, 0))
+
+	router := tf2bdd.CreateRouter(database, testConfig)
+
+	doRequest := func(headers map[string]string) *httptest.ResponseRecorder {
+		req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/steamids", nil)
+		require.NoError(t, errReq)
+
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	first := doRequest(nil)
+	require.Equal(t, http.StatusOK, first.Code)
+	require.Empty(t, first.Header().Get("Content-Encoding"))
+
+	etag := first.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	require.NotEmpty(t, first.Header().Get("Last-Modified"))
+
+	require.Equal(t, http.StatusNotModified, doRequest(map[string]string{"If-None-Match": etag}).Code)
+	require.Equal(t, http.StatusNotModified,
+		doRequest(map[string]string{"If-Modified-Since": first.Header().Get("Last-Modified")}).Code)
+
+	compressed := doRequest(map[string]string{"Accept-Encoding": "gzip, br;q=0.5"})
+	require.Equal(t, http.StatusOK, compressed.Code)
+	require.Equal(t, "gzip", compressed.Header().Get("Content-Encoding"))
+
+	reader, errReader := gzip.NewReader(compressed.Body)
+	require.NoError(t, errReader)
+
+	body, errBody := io.ReadAll(reader)
+	require.NoError(t, errBody)
+	require.Equal(t, first.Body.String(), string(body))
+
+	require.Equal(t, "zstd", doRequest(map[string]string{"Accept-Encoding": "*"}).Header().Get("Content-Encoding"))
+	require.Empty(t, doRequest(map[string]string{"Accept-Encoding": "deflate, gzip;q=0"}).Header().Get("Content-Encoding"))
+
+	require.NoError(t, tf2bdd.AddPlayer(ctx, database, tf2bdd.Player{
+		SteamID:    steamid.New(76561198834913692),
+		Attributes: []string{"cheater"},
+	}, 0))
+
+	changed := doRequest(map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusOK, changed.Code)
+	require.NotEqual(t, etag, changed.Header().Get("ETag"))
+
+	var players tf2bdd.PlayerListRoot
+	require.NoError(t, json.NewDecoder(changed.Body).Decode(&players))
+	require.Len(t, players.Players, 2)
+}
+
+func TestHandleGetSteamIDSExportedAttrs(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		ListAuthors:     []string{"test author"},
+		ExportedAttrs:   []string{"racist"},
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	localPlayers := []tf2bdd.Player{
+		{
+			SteamID:    steamid.New(76561198237337976),
+			Attributes: []string{"cheater", "suspicious"},
+		},
+		{
+			SteamID:    steamid.New(76561198834913692),
+			Attributes: []string{"suspicious", "racist"},
+		},
+	}
+
+	for _, p := range localPlayers {
+		require.NoError(t, tf2bdd.AddPlayer(ctx, database, p, 0))
+	}
+
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/steamids", nil)
+	require.NoError(t, errReq)
+
+	recorder := httptest.NewRecorder()
+	tf2bdd.CreateRouter(database, testConfig).ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var players tf2bdd.PlayerListRoot
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&players))
+	require.Len(t, players.Players, 1)
+	require.Equal(t, localPlayers[1].SteamID, players.Players[0].SteamID)
+	require.Equal(t, localPlayers[1].Attributes, players.Players[0].Attributes)
+}
+
+func TestHandleGetSteamIDSProofFormat(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		ListAuthors:     []string{"test author"},
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	proof := tf2bdd.Proof{
+		{Value: "https://example.com/demo.dem", Note: "round 2"},
+		{Value: "text with ^^ separator"},
+	}
+
+	require.NoError(t, tf2bdd.AddPlayer(ctx, database, tf2bdd.Player{
+		SteamID:    steamid.New(76561198237337976),
+		Attributes: []string{"cheater"},
+		Proof:      proof,
+	}, 0))
+
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/steamids", nil)
+	require.NoError(t, errReq)
+
+	recorder := httptest.NewRecorder()
+	tf2bdd.CreateRouter(database, testConfig).ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var results struct {
+		Players []struct {
+			Proof []string `json:"proof"`
+		} `json:"players"`
+	}
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&results))
+	require.Len(t, results.Players, 1)
+	require.Equal(t, []string{proof[0].Value, proof[1].Value}, results.Players[0].Proof)
+}
+
+func TestHandleGetList(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		ListAuthors:     []string{"test author"},
+		Lists: []tf2bdd.ListConfig{
+			{
+				Name:        "watch",
+				Title:       "watch title",
+				Description: "watch description",
+				Attributes:  []string{"suspicious"},
+			},
+		},
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	localPlayers := []tf2bdd.Player{
+		{
+			SteamID:    steamid.New(76561198237337976),
+			Attributes: []string{"cheater"},
+		},
+		{
+			SteamID:    steamid.New(76561198834913692),
+			Attributes: []string{"suspicious"},
+		},
+	}
+
+	for _, p := range localPlayers {
+		require.NoError(t, tf2bdd.AddPlayer(ctx, database, p, 0))
+	}
+
+	router := tf2bdd.CreateRouter(database, testConfig)
+
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/lists/watch", nil)
+	require.NoError(t, errReq)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var players tf2bdd.PlayerListRoot
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&players))
+	require.Len(t, players.Players, 1)
+	require.Equal(t, localPlayers[1].SteamID, players.Players[0].SteamID)
+	require.Equal(t, "watch title", players.ListSource.Title)
+	require.Equal(t, "watch description", players.ListSource.Description)
+	require.Equal(t, []string{"test author"}, players.ListSource.Authors)
+	require.Equal(t, "https://example.com/v1/lists/watch", players.ListSource.UpdateURL)
+
+	missingReq, errMissingReq := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/lists/unknown", nil)
+	require.NoError(t, errMissingReq)
+
+	missingRecorder := httptest.NewRecorder()
+	router.ServeHTTP(missingRecorder, missingReq)
+	require.Equal(t, http.StatusNotFound, missingRecorder.Code)
+}
+
+func TestHandleGetListGuild(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		Lists: []tf2bdd.ListConfig{
+			{
+				Name:        "guild",
+				Title:       "guild title",
+				Description: "guild description",
+				GuildID:     "123456789",
+			},
+		},
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	localPlayers := []tf2bdd.Player{
+		{
+			SteamID:    steamid.New(76561198237337976),
+			Attributes: []string{"cheater"},
+			GuildID:    "123456789",
+		},
+		{
+			SteamID:    steamid.New(76561198834913692),
+			Attributes: []string{"cheater"},
+			GuildID:    "987654321",
+		},
+		{
+			SteamID:    steamid.New(76561197961279983),
+			Attributes: []string{"cheater"},
+		},
+	}
+
+	for _, p := range localPlayers {
+		require.NoError(t, tf2bdd.AddPlayer(ctx, database, p, 0))
+	}
+
+	router := tf2bdd.CreateRouter(database, testConfig)
+
+	for path, expected := range map[string]int{"/v1/lists/guild": 1, "/v1/steamids": len(localPlayers)} {
+		req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+		require.NoError(t, errReq)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var players tf2bdd.PlayerListRoot
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&players))
+		require.Len(t, players.Players, expected, path)
+	}
+}
+
+func TestHandleLookup(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		ExportedAttrs:   []string{"cheater"},
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	localPlayers := []tf2bdd.Player{
+		{
+			SteamID:    steamid.New(76561198237337976),
+			Attributes: []string{"cheater"},
+			Proof:      tf2bdd.Proof{{Value: "https://example.com/demo", Note: "round 1"}},
+		},
+		{
+			SteamID:    steamid.New(76561198834913692),
+			Attributes: []string{"suspicious"},
+		},
+	}
+
+	for _, p := range localPlayers {
+		require.NoError(t, tf2bdd.AddPlayer(ctx, database, p, 0))
+	}
+
+	router := tf2bdd.CreateRouter(database, testConfig)
+
+	doRequest := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, errReq := http.NewRequestWithContext(ctx, method, path, strings.NewReader(body))
+		require.NoError(t, errReq)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	for _, value := range []string{"76561198237337976", "[U:1:277072248]", "STEAM_0:0:138536124"} {
+		recorder := doRequest(http.MethodGet, "/v1/steamids/"+value, "")
+		require.Equal(t, http.StatusOK, recorder.Code, value)
+
+		var player tf2bdd.Player
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&player))
+		require.Equal(t, localPlayers[0].SteamID, player.SteamID)
+		require.Equal(t, []string{"cheater"}, player.Attributes)
+		require.Equal(t, tf2bdd.Proof{{Value: "https://example.com/demo"}}, player.Proof)
+	}
+
+	require.Equal(t, http.StatusBadRequest, doRequest(http.MethodGet, "/v1/steamids/invalid", "").Code)
+	require.Equal(t, http.StatusNotFound, doRequest(http.MethodGet, "/v1/steamids/76561197960287930", "").Code)
+	// Not exported by the default list.
+	require.Equal(t, http.StatusNotFound, doRequest(http.MethodGet, "/v1/steamids/76561198834913692", "").Code)
+
+	recorder := doRequest(http.MethodPost, "/v1/steamids/lookup",
+		`["76561198237337976", "[U:1:277072248]", "76561198834913692", "76561197960287930"]`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var players []tf2bdd.Player
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&players))
+	require.Len(t, players, 1)
+	require.Equal(t, localPlayers[0].SteamID, players[0].SteamID)
+
+	require.Equal(t, http.StatusBadRequest, doRequest(http.MethodPost, "/v1/steamids/lookup", `["invalid"]`).Code)
+	require.Equal(t, http.StatusBadRequest, doRequest(http.MethodPost, "/v1/steamids/lookup", `{}`).Code)
+}
+
+func TestHandleGetChanges(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		ExportedAttrs:   []string{"cheater"},
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	router := tf2bdd.CreateRouter(database, testConfig)
+
+	getChanges := func(since string) tf2bdd.ChangeSet {
+		req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/changes?since="+since, nil)
+		require.NoError(t, errReq)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var changes tf2bdd.ChangeSet
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&changes))
+
+		return changes
+	}
+
+	cheater := tf2bdd.Player{SteamID: steamid.New(76561198237337976), Attributes: []string{"cheater"}}
+	other := tf2bdd.Player{SteamID: steamid.New(76561198834913692), Attributes: []string{"cheater"}}
+
+	require.NoError(t, tf2bdd.AddPlayer(ctx, database, cheater, 0))
+
+	initial := getChanges("0")
+	require.False(t, initial.ResyncRequired)
+	require.Len(t, initial.Changes, 1)
+	require.Equal(t, "add", string(initial.Changes[0].Action))
+	require.Equal(t, cheater.SteamID, initial.Changes[0].Player.SteamID)
+
+	cursor := strconv.FormatInt(initial.Cursor, 10)
+	require.Empty(t, getChanges(cursor).Changes)
+
+	require.NoError(t, tf2bdd.AddPlayer(ctx, database, other, 0))
+	require.NoError(t, tf2bdd.DropPlayer(ctx, database, cheater.SteamID, 0))
+
+	changes := getChanges(cursor)
+	require.Len(t, changes.Changes, 2)
+	require.Equal(t, "add", string(changes.Changes[0].Action))
+	require.Equal(t, other.SteamID, changes.Changes[0].SteamID)
+	require.Equal(t, "delete", string(changes.Changes[1].Action))
+	require.Equal(t, cheater.SteamID, changes.Changes[1].SteamID)
+	require.Nil(t, changes.Changes[1].Player)
+	require.Greater(t, changes.Cursor, initial.Cursor)
+	require.Empty(t, getChanges(strconv.FormatInt(changes.Cursor, 10)).Changes)
+
+	// Only the latest change of each player is sent.
+	require.Len(t, getChanges("0").Changes, 2)
+
+	require.True(t, getChanges(strconv.FormatInt(changes.Cursor+100, 10)).ResyncRequired)
+
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/changes?since=invalid", nil)
+	require.NoError(t, errReq)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestSyncUpstreams(t *testing.T) {
+	ctx := context.Background()
+
+	upstreamList := tf2bdd.PlayerListRoot{
+		Players: []tf2bdd.Player{
+			{SteamID: steamid.New(76561198237337976), Attributes: []string{"cheater"}},
+			{SteamID: steamid.New(76561198834913692), Attributes: []string{"bot"}},
+			{SteamID: steamid.New(76561197960287930), Attributes: []string{"cheater"}},
+			{SteamID: steamid.New(76561197970669109), Attributes: []string{"unmapped"}},
+		},
+	}
+
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		require.NoError(t, json.NewEncoder(writer).Encode(&upstreamList))
+	}))
+	defer upstreamServer.Close()
+
+	testConfig := tf2bdd.Config{
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		KnownAttributes: []string{"cheater", "suspicious"},
+		Upstreams: []tf2bdd.UpstreamConfig{
+			{
+				Name:       "partner",
+				URL:        upstreamServer.URL,
+				Attributes: map[string]string{"cheater": "cheater", "bot": "suspicious"},
+			},
+		},
+	}
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	local := tf2bdd.Player{SteamID: steamid.New(76561197960287930), Attributes: []string{"suspicious"}}
+	require.NoError(t, tf2bdd.AddPlayer(ctx, database, local, 0))
+
+	results, errSync := tf2bdd.SyncUpstreams(ctx, database, testConfig)
+	require.NoError(t, errSync)
+	require.Equal(t, tf2bdd.UpstreamResult{Added: 2}, results["partner"])
+
+	bot, errBot := tf2bdd.GetPlayer(ctx, database, steamid.New(76561198834913692))
+	require.NoError(t, errBot)
+	require.Equal(t, []string{"suspicious"}, bot.Attributes)
+	require.Equal(t, "partner", bot.Source)
+
+	// Local entries are left untouched.
+	existing, errExisting := tf2bdd.GetPlayer(ctx, database, local.SteamID)
+	require.NoError(t, errExisting)
+	require.Equal(t, []string{"suspicious"}, existing.Attributes)
+	require.Empty(t, existing.Source)
+
+	router := tf2bdd.CreateRouter(database, testConfig)
+
+	getList := func(path string) tf2bdd.PlayerListRoot {
+		req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+		require.NoError(t, errReq)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var list tf2bdd.PlayerListRoot
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&list))
+
+		return list
+	}
+
+	require.Len(t, getList("/v1/steamids").Players, 3)
+	require.Len(t, getList("/v1/steamids?upstream=false").Players, 1)
+
+	upstreamList.Players = []tf2bdd.Player{
+		{SteamID: steamid.New(76561198834913692), Attributes: []string{"bot", "cheater"}},
+		{SteamID: steamid.New(76561197960287930), Attributes: []string{"cheater"}},
+	}
+
+	results, errSync = tf2bdd.SyncUpstreams(ctx, database, testConfig)
+	require.NoError(t, errSync)
+	require.Equal(t, tf2bdd.UpstreamResult{Updated: 1, Removed: 1}, results["partner"])
+
+	bot, errBot = tf2bdd.GetPlayer(ctx, database, steamid.New(76561198834913692))
+	require.NoError(t, errBot)
+	require.Equal(t, []string{"suspicious", "cheater"}, bot.Attributes)
+
+	_, errRemoved := tf2bdd.GetPlayer(ctx, database, steamid.New(76561198237337976))
+	require.ErrorIs(t, errRemoved, tf2bdd.ErrNotFound)
+
+	// Entries removed by the upstream are restored once relisted, unlike entries deleted locally.
+	require.NoError(t, tf2bdd.DropPlayer(ctx, database, steamid.New(76561198834913692), 1))
+
+	upstreamList.Players = []tf2bdd.Player{
+		{SteamID: steamid.New(76561198237337976), Attributes: []string{"bot"}},
+		{SteamID: steamid.New(76561198834913692), Attributes: []string{"bot", "cheater"}},
+		{SteamID: steamid.New(76561197960287930), Attributes: []string{"cheater"}},
+	}
+
+	results, errSync = tf2bdd.SyncUpstreams(ctx, database, testConfig)
+	require.NoError(t, errSync)
+	require.Equal(t, tf2bdd.UpstreamResult{Added: 1}, results["partner"])
+
+	relisted, errRelisted := tf2bdd.GetPlayer(ctx, database, steamid.New(76561198237337976))
+	require.NoError(t, errRelisted)
+	require.Equal(t, []string{"suspicious"}, relisted.Attributes)
+	require.Equal(t, "partner", relisted.Source)
+
+	_, errDeleted := tf2bdd.GetPlayer(ctx, database, steamid.New(76561198834913692))
+	require.ErrorIs(t, errDeleted, tf2bdd.ErrNotFound)
+
+	upstreamList.Players = []tf2bdd.Player{}
+
+	_, errSync = tf2bdd.SyncUpstreams(ctx, database, testConfig)
+	require.Error(t, errSync)
+	require.Len(t, getList("/v1/steamids").Players, 2)
+}
+
+func TestEnrichPlayers(t *testing.T) {
+	ctx := context.Background()
+	requests := 0
+
+	steamServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests++
+
+		require.Equal(t, "key", request.URL.Query().Get("key"))
+		require.Equal(t, "76561197960287930", request.URL.Query().Get("steamids"))
+
+		switch request.URL.Path {
+		case "/ISteamUser/GetPlayerSummaries/v2/":
+			_, _ = io.WriteString(writer, `{"response":{"players":[{"steamid":"76561197960287930",`+
+				`"personaname":"gaben","avatarfull":"https://example.com/avatar.jpg",`+
+				`"communityvisibilitystate":3,"timecreated":1063407589}]}}`)
+		case "/ISteamUser/GetPlayerBans/v1/":
+			_, _ = io.WriteString(writer, `{"players":[{"SteamId":"76561197960287930","NumberOfVACBans":2,"NumberOfGameBans":1}]}`)
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer steamServer.Close()
+
+	testConfig := tf2bdd.Config{
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		KnownAttributes: []string{"cheater", "suspicious"},
+		SteamKey:        "key",
+		SteamAPIURL:     steamServer.URL,
+		EnrichInterval:  time.Hour,
+	}
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	sid := steamid.New(76561197960287930)
+	require.NoError(t, tf2bdd.AddPlayer(ctx, database, tf2bdd.Player{SteamID: sid, Attributes: []string{"cheater"}}, 0))
+
+	router := tf2bdd.CreateRouter(database, testConfig)
+	getList := func() (tf2bdd.PlayerListRoot, string) {
+		req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/steamids", nil)
+		require.NoError(t, errReq)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var list tf2bdd.PlayerListRoot
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&list))
+
+		return list, recorder.Header().Get("ETag")
+	}
+
+	before, etagBefore := getList()
+	require.Nil(t, before.Players[0].Steam)
+
+	updated, errEnrich := tf2bdd.EnrichPlayers(ctx, database, testConfig)
+	require.NoError(t, errEnrich)
+	require.Equal(t, 1, updated)
+	require.Equal(t, 2, requests)
+
+	expected := &tf2bdd.SteamProfile{
+		PersonaName: "gaben",
+		Avatar:      "https://example.com/avatar.jpg",
+		Visibility:  "public",
+		TimeCreated: 1063407589,
+		VACBans:     2,
+		GameBans:    1,
+	}
+
+	player, errPlayer := tf2bdd.GetPlayer(ctx, database, sid)
+	require.NoError(t, errPlayer)
+	require.NotNil(t, player.Steam)
+	require.NotZero(t, player.Steam.UpdatedOn)
+
+	expected.UpdatedOn = player.Steam.UpdatedOn
+	require.Equal(t, expected, player.Steam)
+
+	after, etagAfter := getList()
+	require.NotEqual(t, etagBefore, etagAfter)
+	require.Equal(t, expected, after.Players[0].Steam)
+
+	// Profiles are only fetched again once they are older than the interval.
+	updated, errEnrich = tf2bdd.EnrichPlayers(ctx, database, testConfig)
+	require.NoError(t, errEnrich)
+	require.Zero(t, updated)
+	require.Equal(t, 2, requests)
+
+	// Steam profiles are not part of the entry, so they are not recorded in the audit log.
+	require.NoError(t, tf2bdd.DropPlayer(ctx, database, sid, 0))
+
+	var deleted string
+	require.NoError(t, database.QueryRowContext(ctx,
+		`SELECT before FROM audit_log WHERE steamid = ? AND action = 'delete'`, sid.Int64()).Scan(&deleted))
+
+	var snapshot map[string]any
+	require.NoError(t, json.Unmarshal([]byte(deleted), &snapshot))
+	require.Equal(t, sid.String(), snapshot["steamid"])
+	require.NotContains(t, snapshot, "steam")
+}
+
+func TestNameHistory(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		KnownAttributes: []string{"cheater", "suspicious"},
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	_, readToken, errRead := tf2bdd.CreateAPIToken(ctx, database, tf2bdd.ScopeRead, "reader")
+	require.NoError(t, errRead)
+
+	sid := steamid.New(76561198237337976)
+	player := tf2bdd.Player{
+		SteamID:    sid,
+		Attributes: []string{"cheater"},
+		LastSeen:   tf2bdd.LastSeen{PlayerName: "bot one", Time: 1000},
+	}
+	require.NoError(t, tf2bdd.AddPlayer(ctx, database, player, 0))
+
+	// Names of players that are already listed are recorded by imports.
+	added, errImport := tf2bdd.ImportPlayerList(ctx, database, testConfig, tf2bdd.PlayerListRoot{
+		Players: []tf2bdd.Player{
+			{SteamID: sid, Attributes: []string{"cheater"}, LastSeen: tf2bdd.LastSeen{PlayerName: "bot two", Time: 2000}},
+			{SteamID: sid, Attributes: []string{"cheater"}, LastSeen: tf2bdd.LastSeen{PlayerName: "bot one", Time: 3000}},
+		},
+	}, 0)
+	require.NoError(t, errImport)
+	require.Zero(t, added)
+
+	router := tf2bdd.CreateRouter(database, testConfig)
+	getNames := func(steamID string) *httptest.ResponseRecorder {
+		req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/players/"+steamID+"/names", nil)
+		require.NoError(t, errReq)
+		req.Header.Set("Authorization", "Bearer "+readToken)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	recorder := getNames(sid.String())
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var names []tf2bdd.NameHistoryEntry
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&names))
+	require.Len(t, names, 2)
+	require.Equal(t, "bot one", names[0].Name)
+	require.Equal(t, "entry", names[0].Source)
+	require.Equal(t, int64(1000), names[0].FirstSeen.Unix())
+	require.Equal(t, int64(3000), names[0].LastSeen.Unix())
+	require.Equal(t, "bot two", names[1].Name)
+	require.Equal(t, "import", names[1].Source)
+	require.Equal(t, int64(2000), names[1].FirstSeen.Unix())
+
+	require.Equal(t, http.StatusNotFound, getNames("76561197960287930").Code)
+}
+
+func TestImportPlayerList(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		KnownAttributes: []string{"cheater", "suspicious"},
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	present := steamid.New(76561197960287930)
+	conflict := steamid.New(76561198237337976)
+	deleted := steamid.New(76561197960265729)
+	added := steamid.New(76561197960265730)
+	repeated := steamid.New(76561197960265732)
+
+	for _, sid := range []steamid.SteamID{present, conflict, deleted} {
+		require.NoError(t, tf2bdd.AddPlayer(ctx, database, tf2bdd.Player{SteamID: sid, Attributes: []string{"cheater"}}, 0))
+	}
+	require.NoError(t, tf2bdd.DropPlayer(ctx, database, deleted, 0))
+
+	playerList := tf2bdd.PlayerListRoot{
+		Players: []tf2bdd.Player{
+			{SteamID: present, Attributes: []string{"cheater"}},
+			{SteamID: conflict, Attributes: []string{"suspicious"}},
+			{SteamID: deleted, Attributes: []string{"cheater"}},
+			{SteamID: added, Attributes: []string{"cheater", "unknown"}},
+			{SteamID: added, Attributes: []string{"suspicious"}},
+			{SteamID: steamid.New(76561197960265731), Attributes: []string{"unknown"}},
+			{SteamID: repeated, Attributes: []string{"unknown"}},
+			{SteamID: repeated, Attributes: []string{"suspicious"}},
+		},
+	}
+
+	diff, errDiff := tf2bdd.DiffImport(ctx, database, testConfig, playerList)
+	require.NoError(t, errDiff)
+	require.Len(t, diff.New, 2)
+	require.Equal(t, added, diff.New[0].SteamID)
+	require.Equal(t, []string{"cheater"}, diff.New[0].Attributes)
+	// Repeats are used when the earlier entries have no known attributes.
+	require.Equal(t, repeated, diff.New[1].SteamID)
+	require.Equal(t, []string{"suspicious"}, diff.New[1].Attributes)
+	require.Len(t, diff.Present, 1)
+	require.Equal(t, present, diff.Present[0].SteamID)
+	require.Len(t, diff.Conflicts, 1)
+	require.Equal(t, conflict, diff.Conflicts[0].Player.SteamID)
+	require.Equal(t, []string{"cheater"}, diff.Conflicts[0].Existing)
+	require.Len(t, diff.Deleted, 1)
+	require.Equal(t, 3, diff.Skipped)
+
+	// A dry run does not change anything.
+	_, errMissing := tf2bdd.GetPlayer(ctx, database, added)
+	require.ErrorIs(t, errMissing, tf2bdd.ErrNotFound)
+
+	count, errImport := tf2bdd.ImportPlayerList(ctx, database, testConfig, playerList, 0)
+	require.NoError(t, errImport)
+	require.Equal(t, 2, count)
+
+	player, errPlayer := tf2bdd.GetPlayer(ctx, database, added)
+	require.NoError(t, errPlayer)
+	require.Equal(t, []string{"cheater"}, player.Attributes)
+
+	existing, errExisting := tf2bdd.GetPlayer(ctx, database, conflict)
+	require.NoError(t, errExisting)
+	require.Equal(t, []string{"cheater"}, existing.Attributes)
+
+	count, errImport = tf2bdd.ImportPlayerList(ctx, database, testConfig, playerList, 0)
+	require.NoError(t, errImport)
+	require.Zero(t, count)
+
+	// Imported entries are held for confirmation when required, the importing user counting as the first.
+	testConfig.RequiredConfirmations = 2
+	unconfirmed := steamid.New(76561197960265731)
+
+	count, errImport = tf2bdd.ImportPlayerList(ctx, database, testConfig, tf2bdd.PlayerListRoot{
+		Players: []tf2bdd.Player{{SteamID: unconfirmed, Attributes: []string{"cheater"}}},
+	}, 1)
+	require.NoError(t, errImport)
+	require.Equal(t, 1, count)
+
+	player, errPlayer = tf2bdd.GetPlayer(ctx, database, unconfirmed)
+	require.NoError(t, errPlayer)
+	require.False(t, player.Confirmed)
+
+	_, errDuplicate := tf2bdd.ConfirmEntry(ctx, database, testConfig, unconfirmed, 1)
+	require.ErrorContains(t, errDuplicate, "already confirmed")
+
+	response, errConfirm := tf2bdd.ConfirmEntry(ctx, database, testConfig, unconfirmed, 2)
+	require.NoError(t, errConfirm)
+	require.Contains(t, response, "confirmed and published")
+}
+
+func TestPlayerAPI(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		KnownAttributes: []string{"cheater", "suspicious"},
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	_, readToken, errRead := tf2bdd.CreateAPIToken(ctx, database, tf2bdd.ScopeRead, "reader")
+	require.NoError(t, errRead)
+
+	_, writeToken, errWrite := tf2bdd.CreateAPIToken(ctx, database, tf2bdd.ScopeWrite, "plugin")
+	require.NoError(t, errWrite)
+
+	adminInfo, adminToken, errAdmin := tf2bdd.CreateAPIToken(ctx, database, tf2bdd.ScopeAdmin, "dashboard")
+	require.NoError(t, errAdmin)
+
+	router := tf2bdd.CreateRouter(database, testConfig)
+
+	doRequest := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		req, errReq := http.NewRequestWithContext(ctx, method, path, strings.NewReader(body))
+		require.NoError(t, errReq)
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	const (
+		sid     = "76561198237337976"
+		newBody = `{"steamid": "` + sid + `", "attributes": ["cheater"], "proof": [{"value": "https://example.com/demo", "note": "round 1"}]}`
+	)
+
+	require.Equal(t, http.StatusUnauthorized, doRequest(http.MethodPost, "/v1/players", "", newBody).Code)
+	require.Equal(t, http.StatusUnauthorized, doRequest(http.MethodPost, "/v1/players", "invalid", newBody).Code)
+	require.Equal(t, http.StatusForbidden, doRequest(http.MethodPost, "/v1/players", readToken, newBody).Code)
+	require.Equal(t, http.StatusBadRequest, doRequest(http.MethodPost, "/v1/players", writeToken,
+		`{"steamid": "`+sid+`", "attributes": ["chaeter"]}`).Code)
+
+	require.Equal(t, http.StatusCreated, doRequest(http.MethodPost, "/v1/players", writeToken, newBody).Code)
+	require.Equal(t, http.StatusConflict, doRequest(http.MethodPost, "/v1/players", writeToken, newBody).Code)
+
+	var player struct {
+		SteamID    string   `json:"steamid"`
+		Attributes []string `json:"attributes"`
+		LastSeen   struct {
+			PlayerName string `json:"player_name"`
+		} `json:"last_seen"`
+		Proof []struct {
+			Value string `json:"value"`
+			Note  string `json:"note"`
+		} `json:"proof"`
+	}
+
+	updated := doRequest(http.MethodPatch, "/v1/players/"+sid, writeToken, `{"attributes": ["suspicious"], "name": "bot"}`)
+	require.Equal(t, http.StatusOK, updated.Code)
+
+	require.Equal(t, http.StatusCreated, doRequest(http.MethodPost, "/v1/players/"+sid+"/proof", writeToken,
+		`{"value": "more proof"}`).Code)
+	require.Equal(t, http.StatusConflict, doRequest(http.MethodPost, "/v1/players/"+sid+"/proof", writeToken,
+		`{"value": "more proof"}`).Code)
+
+	found := doRequest(http.MethodGet, "/v1/players/"+sid, readToken, "")
+	require.Equal(t, http.StatusOK, found.Code)
+	require.NoError(t, json.NewDecoder(found.Body).Decode(&player))
+	require.Equal(t, sid, player.SteamID)
+	require.Equal(t, []string{"suspicious"}, player.Attributes)
+	require.Equal(t, "bot", player.LastSeen.PlayerName)
+	require.Len(t, player.Proof, 2)
+	require.Equal(t, "round 1", player.Proof[0].Note)
+
+	require.Equal(t, http.StatusForbidden, doRequest(http.MethodDelete, "/v1/players/"+sid, writeToken, "").Code)
+	require.Equal(t, http.StatusNoContent, doRequest(http.MethodDelete, "/v1/players/"+sid, adminToken, "").Code)
+	require.Equal(t, http.StatusNotFound, doRequest(http.MethodGet, "/v1/players/"+sid, readToken, "").Code)
+
+	require.NoError(t, tf2bdd.RevokeAPIToken(ctx, database, adminInfo.TokenID))
+	require.Equal(t, http.StatusUnauthorized, doRequest(http.MethodGet, "/v1/players/"+sid, adminToken, "").Code)
+}
+
+func TestPlayerAPIServeMode(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		Mode:            tf2bdd.ModeServe,
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		KnownAttributes: []string{"cheater", "suspicious"},
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	_, adminToken, errAdmin := tf2bdd.CreateAPIToken(ctx, database, tf2bdd.ScopeAdmin, "dashboard")
+	require.NoError(t, errAdmin)
+
+	const sid = "76561198237337976"
+
+	require.NoError(t, tf2bdd.AddPlayer(ctx, database, tf2bdd.Player{
+		SteamID:    steamid.New(sid),
+		Attributes: []string{"cheater"},
+		Confirmed:  true,
+	}, 0))
+
+	router := tf2bdd.CreateRouter(database, testConfig)
+
+	doRequest := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, errReq := http.NewRequestWithContext(ctx, method, path, strings.NewReader(body))
+		require.NoError(t, errReq)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	require.Equal(t, http.StatusOK, doRequest(http.MethodGet, "/v1/players/"+sid, "").Code)
+	require.Equal(t, http.StatusOK, doRequest(http.MethodGet, "/v1/players/"+sid+"/names", "").Code)
+
+	require.Equal(t, http.StatusNotFound, doRequest(http.MethodPost, "/v1/players",
+		`{"steamid": "76561197960287930", "attributes": ["cheater"]}`).Code)
+	require.Equal(t, http.StatusMethodNotAllowed, doRequest(http.MethodPatch, "/v1/players/"+sid,
+		`{"attributes": ["suspicious"]}`).Code)
+	require.Equal(t, http.StatusMethodNotAllowed, doRequest(http.MethodDelete, "/v1/players/"+sid, "").Code)
+	require.Equal(t, http.StatusNotFound, doRequest(http.MethodPost, "/v1/players/"+sid+"/proof",
+		`{"value": "more proof"}`).Code)
+
+	player, errPlayer := tf2bdd.GetPlayer(ctx, database, steamid.New(sid))
+	require.NoError(t, errPlayer)
+	require.Equal(t, []string{"cheater"}, player.Attributes)
+	require.Empty(t, player.Proof)
+}
+
+func TestHandleGetProof(t *testing.T) {
+	proofDir := t.TempDir()
+	testConfig := tf2bdd.Config{ProofDir: proofDir}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	content := []byte("\x89PNG\r\n\x1a\n not really a png")
+	hash := "1ebc84b4c1f2ee5bad2b4cb14ec7e2b2cc1de2f4b2fc4bb1b3d61e5e8ed1a9a3"
+
+	require.NoError(t, os.MkdirAll(filepath.Join(proofDir, hash[:2]), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(proofDir, hash[:2], hash), content, 0o600))
+
+	router := tf2bdd.CreateRouter(database, testConfig)
+
+	for path, status := range map[string]int{
+		"/v1/proof/" + hash:       http.StatusOK,
+		"/v1/proof/" + hash[:63]:  http.StatusNotFound,
+		"/v1/proof/..%2f..%2fetc": http.StatusNotFound,
+	} {
+		req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+		require.NoError(t, errReq)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		require.Equal(t, status, recorder.Code, path)
+
+		if status == http.StatusOK {
+			require.Equal(t, "image/png", recorder.Header().Get("Content-Type"))
+			require.Equal(t, content, recorder.Body.Bytes())
+		}
+	}
+
+	// Proof downloads extend the write deadline of the connection beyond the server wide timeout.
+	server := httptest.NewUnstartedServer(router)
+	server.Config = tf2bdd.CreateHTTPServer(router, "")
+	server.Start()
+	defer server.Close()
+
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/v1/proof/"+hash, nil)
+	require.NoError(t, errReq)
+
+	resp, errResp := server.Client().Do(req)
+	require.NoError(t, errResp)
+
+	body, errBody := io.ReadAll(resp.Body)
+	require.NoError(t, errBody)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, content, body)
+
+	// A 25MiB demo is given time to download at 64KiB/s.
+	require.GreaterOrEqual(t, tf2bdd.ProofWriteDeadline(25<<20), 400*time.Second)
+	require.Equal(t, 5*time.Second, tf2bdd.ProofWriteDeadline(int64(len(content))))
+}
+
+func TestAuditSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	sid := steamid.New(76561197960287930)
+	player := tf2bdd.Player{
+		SteamID:    sid,
+		Attributes: []string{"cheater"},
+		LastSeen:   tf2bdd.LastSeen{PlayerName: "bot one", Time: 1000},
+		Proof:      tf2bdd.Proof{{Value: "https://example.com/demo.dem", Note: "round 2"}},
+	}
+	require.NoError(t, tf2bdd.AddPlayer(ctx, database, player, 1234))
+	require.NoError(t, tf2bdd.DropPlayer(ctx, database, sid, 1234))
+
+	var after string
+	require.NoError(t, database.QueryRowContext(ctx,
+		`SELECT after FROM audit_log WHERE steamid = ? AND action = 'add'`, sid.Int64()).Scan(&after))
+
+	var raw map[string]any
+	require.NoError(t, json.Unmarshal([]byte(after), &raw))
+	require.Equal(t, sid.String(), raw["steamid"])
+
+	entries, errEntries := tf2bdd.GetAuditLog(ctx, database, sid, 10)
+	require.NoError(t, errEntries)
+	require.Len(t, entries, 2)
+
+	deleted, added := entries[0], entries[1]
+	require.Equal(t, "add", added.Action)
+	require.Nil(t, added.Before)
+	require.NotNil(t, added.After)
+	require.Equal(t, sid, added.After.SteamID)
+	require.Equal(t, player.Attributes, added.After.Attributes)
+	require.Equal(t, player.LastSeen, added.After.LastSeen)
+	require.Equal(t, int64(1234), added.After.Author)
+	require.Len(t, added.After.Proof, 1)
+	require.Equal(t, "https://example.com/demo.dem", added.After.Proof[0].Value)
+	require.Equal(t, "round 2", added.After.Proof[0].Note)
+
+	require.Equal(t, "delete", deleted.Action)
+	require.NotNil(t, deleted.Before)
+	require.Equal(t, sid, deleted.Before.SteamID)
+	require.Equal(t, added.After.Attributes, deleted.Before.Attributes)
+}
+
+func TestDeleteRestorePurge(t *testing.T) {
+	testConfig := tf2bdd.Config{
+		ExternalURL:     "https://example.com/",
+		ListTitle:       "test title",
+		ListDescription: "test description",
+		KnownAttributes: []string{"cheater", "suspicious"},
+	}
+	ctx := context.Background()
+
+	database, errApp := newTestDB()
+	require.NoError(t, errApp)
+
+	recent := steamid.New(76561197960287930)
+	expired := steamid.New(76561197960265729)
+
+	listed := func() []steamid.SteamID {
+		playerList, errList := tf2bdd.ExportPlayerList(ctx, database, testConfig, true)
+		require.NoError(t, errList)
+
+		var sids []steamid.SteamID
+		for _, player := range playerList.Players {
+			sids = append(sids, player.SteamID)
+		}
+
+		return sids
+	}
+
+	for _, sid := range []steamid.SteamID{recent, expired} {
+		require.NoError(t, tf2bdd.AddPlayer(ctx, database, tf2bdd.Player{SteamID: sid, Attributes: []string{"cheater"}}, 0))
+	}
+	require.Len(t, listed(), 2)
+
+	// Deleted entries are hidden, but can be restored until they are purged.
+	require.NoError(t, tf2bdd.DropPlayer(ctx, database, recent, 1))
+	require.Equal(t, []steamid.SteamID{expired}, listed())
+
+	_, errDeleted := tf2bdd.GetPlayer(ctx, database, recent)
+	require.ErrorIs(t, errDeleted, tf2bdd.ErrNotFound)
+
+	require.NoError(t, tf2bdd.RestorePlayer(ctx, database, recent, 1))
+	require.Len(t, listed(), 2)
+	require.ErrorIs(t, tf2bdd.RestorePlayer(ctx, database, recent, 1), tf2bdd.ErrNotFound)
+
+	require.NoError(t, tf2bdd.DropPlayer(ctx, database, recent, 1))
+	require.NoError(t, tf2bdd.DropPlayer(ctx, database, expired, 1))
+
+	_, errExec := database.ExecContext(ctx, `UPDATE player SET deleted_on = ? WHERE steamid = ?`,
+		time.Now().Add(-48*time.Hour).Unix(), expired.Int64())
+	require.NoError(t, errExec)
+
+	// Only entries deleted longer than purge_deleted_after ago are removed.
+	purged, errPurge := tf2bdd.PurgeDeletedPlayers(ctx, database, time.Now().Add(-24*time.Hour))
+	require.NoError(t, errPurge)
+	require.Equal(t, 1, purged)
+	require.Empty(t, listed())
+
+	require.ErrorIs(t, tf2bdd.RestorePlayer(ctx, database, expired, 1), tf2bdd.ErrNotFound)
+
+	var remaining int
+	require.NoError(t, database.QueryRowContext(ctx, `SELECT count(*) FROM player WHERE steamid = ?`,
+		expired.Int64()).Scan(&remaining))
+	require.Zero(t, remaining)
+
+	require.NoError(t, tf2bdd.RestorePlayer(ctx, database, recent, 1))
+	require.Equal(t, []steamid.SteamID{recent}, listed())
+}
+
 func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
@@ -0,0 +1,418 @@
+package tf2bdd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func proofIndexOption(name string, description string) *discordgo.ApplicationCommandOption {
+	minIndex := 0.0
+
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionInteger,
+		Name:        name,
+		Description: description,
+		Required:    true,
+		MinValue:    &minIndex,
+	}
+}
+
+func proofNoteOption() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "note",
+		Description: "Optional note describing what the proof shows",
+	}
+}
+
+func steamIDOption() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "steamid",
+		Description: "SteamID in any format, vanity name or profile link",
+		Required:    true,
+	}
+}
+
+// steamIDsOption accepts several steam ids separated by spaces, for the commands supporting bulk changes.
+func steamIDsOption() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "steamid",
+		Description: "One or more SteamIDs in any format, vanity names or profile links, separated by spaces",
+		Required:    true,
+	}
+}
+
+// maxOptionChoices is the maximum amount of choices discord allows for a single option.
+const maxOptionChoices = 25
+
+// maxAttributeOptions is the amount of attribute choice options, allowing several attributes to be applied
+// at once like the text commands.
+const maxAttributeOptions = 3
+
+func attributeOption(name string, description string, knownAttributes []string) *discordgo.ApplicationCommandOption {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, min(len(knownAttributes), maxOptionChoices))
+	for idx, attr := range knownAttributes[:len(choices)] {
+		choices[idx] = &discordgo.ApplicationCommandOptionChoice{Name: attr, Value: attr}
+	}
+
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        name,
+		Description: description,
+		Choices:     choices,
+	}
+}
+
+// attributeOptions returns the optional attribute choice options, named attribute, attribute2 and so on.
+func attributeOptions(knownAttributes []string) []*discordgo.ApplicationCommandOption {
+	options := []*discordgo.ApplicationCommandOption{
+		attributeOption("attribute", "Attribute to apply to the player, defaults to "+knownAttributes[0], knownAttributes),
+	}
+
+	for idx := 2; idx <= maxAttributeOptions; idx++ {
+		options = append(options, attributeOption("attribute"+strconv.Itoa(idx),
+			"Additional attribute to apply to the player", knownAttributes))
+	}
+
+	return options
+}
+
+// isAttributeOption returns true for the options created by attributeOptions.
+func isAttributeOption(name string) bool {
+	return strings.HasPrefix(name, "attribute") && name != "attributes"
+}
+
+func attributesOption(knownAttributes []string) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "attributes",
+		Description: "Space separated attributes, one or more of: " + strings.Join(knownAttributes, ", "),
+		Required:    true,
+	}
+}
+
+// slashCommands defines the application commands registered with discord. The options of each command are
+// defined in the same order as the positional arguments of the equivalent prefixed text command, except
+// for optional attribute options, as discord requires the required options to come first.
+func slashCommands(config Config) []*discordgo.ApplicationCommand {
+	return []*discordgo.ApplicationCommand{
+		{
+			Name:        "add",
+			Description: "Add one or more players to the list",
+			Options:     append([]*discordgo.ApplicationCommandOption{steamIDsOption()}, attributeOptions(config.KnownAttributes)...),
+		},
+		{
+			Name:        "setattr",
+			Description: "Replace the attributes of a player",
+			Options:     []*discordgo.ApplicationCommandOption{steamIDOption(), attributesOption(config.KnownAttributes)},
+		},
+		{
+			Name:        "addattr",
+			Description: "Add attributes to a player",
+			Options:     []*discordgo.ApplicationCommandOption{steamIDOption(), attributesOption(config.KnownAttributes)},
+		},
+		{
+			Name:        "rmattr",
+			Description: "Remove attributes from a player",
+			Options:     []*discordgo.ApplicationCommandOption{steamIDOption(), attributesOption(config.KnownAttributes)},
+		},
+		{
+			Name:        "del",
+			Description: "Remove one or more players from the list",
+			Options:     []*discordgo.ApplicationCommandOption{steamIDsOption()},
+		},
+		{
+			Name:        "restore",
+			Description: "Restore a previously deleted player",
+			Options:     []*discordgo.ApplicationCommandOption{steamIDOption()},
+		},
+		{
+			Name:        "confirm",
+			Description: "Confirm an entry that is awaiting confirmation",
+			Options:     []*discordgo.ApplicationCommandOption{steamIDOption()},
+		},
+		{
+			Name:        "claim",
+			Description: "Take over an entry from an upstream list, so it is no longer changed by the upstream",
+			Options:     []*discordgo.ApplicationCommandOption{steamIDOption()},
+		},
+		{
+			Name:        "check",
+			Description: "Check if a player exists in the list",
+			Options:     []*discordgo.ApplicationCommandOption{steamIDOption()},
+		},
+		{
+			Name:        "history",
+			Description: "Show the change history of a player",
+			Options:     []*discordgo.ApplicationCommandOption{steamIDOption()},
+		},
+		{
+			Name:        "names",
+			Description: "Show the names a player has been seen using",
+			Options:     []*discordgo.ApplicationCommandOption{steamIDOption()},
+		},
+		{
+			Name:        "lobby",
+			Description: "Check every player of the status console command output",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "status",
+					Description: "Output of the status console command",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionAttachment,
+					Name:        "file",
+					Description: "Text file containing the output of the status console command",
+				},
+			},
+		},
+		{
+			Name:        "addproof",
+			Description: "Add a proof entry to a player",
+			Options: []*discordgo.ApplicationCommandOption{
+				steamIDOption(),
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "proof",
+					Description: "Proof value, can be any string or url",
+				},
+				proofNoteOption(),
+				{
+					Type:        discordgo.ApplicationCommandOptionAttachment,
+					Name:        "file",
+					Description: "Demo, screenshot or video to store as proof",
+				},
+			},
+		},
+		{
+			Name:        "rmproof",
+			Description: "Remove a proof entry from a player",
+			Options: []*discordgo.ApplicationCommandOption{
+				steamIDOption(),
+				proofIndexOption("index", "Index of the proof entry, as shown by check"),
+			},
+		},
+		{
+			Name:        "editproof",
+			Description: "Replace a proof entry of a player",
+			Options: []*discordgo.ApplicationCommandOption{
+				steamIDOption(),
+				proofIndexOption("index", "Index of the proof entry, as shown by check"),
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "proof",
+					Description: "New proof value, can be any string or url",
+					Required:    true,
+				},
+				proofNoteOption(),
+			},
+		},
+		{
+			Name:        "moveproof",
+			Description: "Move a proof entry of a player to a new position",
+			Options: []*discordgo.ApplicationCommandOption{
+				steamIDOption(),
+				proofIndexOption("index", "Index of the proof entry, as shown by check"),
+				proofIndexOption("position", "New index of the proof entry"),
+			},
+		},
+		{
+			Name:        "report",
+			Description: "Submit a player for review",
+			Options: append([]*discordgo.ApplicationCommandOption{
+				steamIDOption(),
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "proof",
+					Description: "Proof value, can be any string or url",
+					Required:    true,
+				},
+				proofNoteOption(),
+			}, attributeOptions(config.KnownAttributes)...),
+		},
+		{
+			Name:        "count",
+			Description: "Show the current count of players tracked",
+		},
+		{
+			Name:        "steamid",
+			Description: "Show all forms of a SteamID",
+			Options:     []*discordgo.ApplicationCommandOption{steamIDOption()},
+		},
+		{
+			Name:        "import",
+			Description: "Import the players from a playerlist json file",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionAttachment,
+					Name:        "playerlist",
+					Description: "Playerlist json file",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "dry_run",
+					Description: "Preview the changes, importing them once confirmed with a reaction",
+				},
+			},
+		},
+		{
+			Name:        "link",
+			Description: "Show the url of the list",
+		},
+	}
+}
+
+func registerSlashCommands(session *discordgo.Session, config Config) error {
+	if _, errRegister := session.ApplicationCommandBulkOverwrite(session.State.User.ID, "", slashCommands(config)); errRegister != nil {
+		return errors.Join(errRegister, errors.New("failed to register slash commands"))
+	}
+
+	return nil
+}
+
+// slashCommandRequest converts the interaction options into the positional args used by the prefixed
+// text commands so that both share the same handlers.
+func slashCommandRequest(interaction *discordgo.InteractionCreate, config Config) commandRequest {
+	data := interaction.ApplicationCommandData()
+
+	req := commandRequest{
+		guildID:   interaction.GuildID,
+		channelID: interaction.ChannelID,
+		args:      []string{data.Name},
+	}
+
+	if interaction.Member != nil {
+		req.authorID = interaction.Member.User.ID
+	} else if interaction.User != nil {
+		req.authorID = interaction.User.ID
+	}
+
+	values := map[string]*discordgo.ApplicationCommandInteractionDataOption{}
+	for _, option := range data.Options {
+		values[option.Name] = option
+	}
+
+	for _, command := range slashCommands(config) {
+		if command.Name != data.Name {
+			continue
+		}
+
+		attributeCount := 0
+
+		for _, option := range command.Options {
+			value, found := values[option.Name]
+			if !found {
+				continue
+			}
+
+			switch option.Type { //nolint:exhaustive
+			case discordgo.ApplicationCommandOptionAttachment:
+				// StringValue panics for non string options, the attachment id is read from the raw value instead.
+				attachmentID, _ := value.Value.(string)
+				if data.Resolved != nil {
+					if attachment, ok := data.Resolved.Attachments[attachmentID]; ok {
+						req.attachments = append(req.attachments, attachment)
+					}
+				}
+			case discordgo.ApplicationCommandOptionInteger:
+				req.args = append(req.args, strconv.FormatInt(value.IntValue(), 10))
+			case discordgo.ApplicationCommandOptionBoolean:
+				// Boolean options map to the matching text command flag.
+				if value.BoolValue() {
+					req.args = append(req.args, "--"+strings.ReplaceAll(option.Name, "_", "-"))
+				}
+			default:
+				if isAttributeOption(option.Name) && len(req.args) > 1 {
+					// The attributes always directly follow the steam id in the text commands.
+					req.args = slices.Insert(req.args, 2+attributeCount, value.StringValue())
+					attributeCount++
+
+					continue
+				}
+
+				if option.Name == "note" {
+					// Notes are appended to the proof value using the same separator as the text commands.
+					req.args = append(req.args, "|")
+				}
+
+				req.args = append(req.args, value.StringValue())
+			}
+		}
+	}
+
+	return req
+}
+
+func interactionCreate(ctx context.Context, database *sql.DB, config Config) func(*discordgo.Session, *discordgo.InteractionCreate) {
+	return func(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+		if interaction.Type == discordgo.InteractionMessageComponent {
+			handleComponent(ctx, session, database, config, interaction)
+
+			return
+		}
+
+		if interaction.Type != discordgo.InteractionApplicationCommand {
+			return
+		}
+
+		// Resolving steam ids and importing can take longer than the initial response window allows.
+		if errDefer := session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		}); errDefer != nil {
+			slog.Error("Failed to respond to interaction", slog.String("error", errDefer.Error()))
+
+			return
+		}
+
+		response, errCmd := handleCommand(ctx, session, database, config, slashCommandRequest(interaction, config))
+		if errCmd != nil {
+			response = errCmd.Error()
+		}
+
+		sendInteractionMsg(session, interaction, response)
+	}
+}
+
+func sendInteractionMsg(session *discordgo.Session, interaction *discordgo.InteractionCreate, msg string) {
+	if _, err := session.InteractionResponseEdit(interaction.Interaction, &discordgo.WebhookEdit{Content: &msg}); err != nil {
+		slog.Error("Failed to edit interaction response", slog.String("msg", msg), slog.String("error", err.Error()))
+	}
+}
+
+// handleComponent routes message component interactions, such as button presses, using the prefix of their custom id.
+func handleComponent(ctx context.Context, session *discordgo.Session, database *sql.DB, config Config, interaction *discordgo.InteractionCreate) {
+	parts := strings.Split(interaction.MessageComponentData().CustomID, ":")
+
+	switch {
+	case len(parts) == 3 && parts[0] == reportComponentPrefix:
+		handleReviewComponent(ctx, session, database, config, interaction, parts[1], parts[2])
+	case len(parts) == 3 && parts[0] == lobbyComponentPrefix && parts[1] == "add":
+		handleLobbyComponent(ctx, session, database, config, interaction, parts[2])
+	default:
+		sendEphemeralMsg(session, interaction, "Unknown action")
+	}
+}
+
+// sendEphemeralMsg responds to an interaction with a message only visible to the user who triggered it.
+func sendEphemeralMsg(session *discordgo.Session, interaction *discordgo.InteractionCreate, msg string) {
+	if err := session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: msg,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}); err != nil {
+		slog.Error("Failed to respond to interaction", slog.String("msg", msg), slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,372 @@
+package tf2bdd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/leighmacdonald/steamid/v4/steamid"
+)
+
+// steamBatchSize is the maximum amount of steam ids the steam web api accepts per request.
+const steamBatchSize = 100
+
+// enrichCheckInterval is how often the enricher looks for profiles that are missing or out of date.
+const enrichCheckInterval = 10 * time.Minute
+
+// SteamProfile is the public steam profile and ban status of a player, as last fetched from the steam web api.
+type SteamProfile struct {
+	PersonaName string `json:"persona_name"`
+	Avatar      string `json:"avatar"`
+	// Visibility is one of public, friends_only or private.
+	Visibility string `json:"visibility"`
+	// TimeCreated is only available for public profiles.
+	TimeCreated int64 `json:"time_created"`
+	VACBans     int   `json:"vac_bans"`
+	GameBans    int   `json:"game_bans"`
+	UpdatedOn   int64 `json:"updated_on"`
+}
+
+const steamProfileColumns = `steamid, persona_name, avatar, visibility, time_created, vac_bans, game_bans, updated_on`
+
+func getSteamProfile(ctx context.Context, db querier, steamID steamid.SteamID) (*SteamProfile, error) {
+	const query = `SELECT ` + steamProfileColumns + ` FROM steam_profile WHERE steamid = ?`
+
+	profiles, errProfiles := querySteamProfiles(ctx, db, query, steamID.Int64())
+	if errProfiles != nil {
+		return nil, errProfiles
+	}
+
+	return profiles[steamID.Int64()], nil
+}
+
+// getAllSteamProfiles returns the steam profile of every player that has one, keyed by steam id.
+func getAllSteamProfiles(ctx context.Context, db querier) (map[int64]*SteamProfile, error) {
+	return querySteamProfiles(ctx, db, `SELECT `+steamProfileColumns+` FROM steam_profile`)
+}
+
+func querySteamProfiles(ctx context.Context, db querier, query string, args ...any) (map[int64]*SteamProfile, error) {
+	rows, errQuery := db.QueryContext(ctx, query, args...)
+	if errQuery != nil {
+		return nil, errors.Join(errQuery, errors.New("failed to load steam profiles"))
+	}
+
+	defer func() {
+		if errClose := rows.Close(); errClose != nil {
+			slog.Error("Failed to close rows handle", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	profiles := map[int64]*SteamProfile{}
+
+	for rows.Next() {
+		var (
+			sid     int64
+			profile SteamProfile
+		)
+
+		if errScan := rows.Scan(&sid, &profile.PersonaName, &profile.Avatar, &profile.Visibility, &profile.TimeCreated,
+			&profile.VACBans, &profile.GameBans, &profile.UpdatedOn); errScan != nil {
+			return nil, errors.Join(errScan, errors.New("error scanning steam profile row"))
+		}
+
+		profiles[sid] = &profile
+	}
+
+	if rows.Err() != nil {
+		return nil, errors.Join(rows.Err(), errors.New("error reading steam profile rows"))
+	}
+
+	return profiles, nil
+}
+
+// saveSteamProfiles stores the profiles and bumps the enrichment version, so that cached lists are rebuilt.
+func saveSteamProfiles(ctx context.Context, database *sql.DB, profiles map[int64]SteamProfile) error {
+	return withTx(ctx, database, func(tx *sql.Tx) error {
+		const query = `
+			INSERT INTO steam_profile (` + steamProfileColumns + `)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (steamid) DO UPDATE
+			SET persona_name = excluded.persona_name,
+			    avatar = excluded.avatar,
+			    visibility = excluded.visibility,
+			    time_created = excluded.time_created,
+			    vac_bans = excluded.vac_bans,
+			    game_bans = excluded.game_bans,
+			    updated_on = excluded.updated_on`
+
+		for sid, profile := range profiles {
+			if _, errExec := tx.ExecContext(ctx, query, sid, profile.PersonaName, profile.Avatar, profile.Visibility,
+				profile.TimeCreated, profile.VACBans, profile.GameBans, profile.UpdatedOn); errExec != nil {
+				return errors.Join(errExec, errors.New("failed to save steam profile"))
+			}
+
+			if errName := observeName(ctx, tx, steamid.New(sid), profile.PersonaName, nameSourceSteam, profile.UpdatedOn); errName != nil {
+				return errName
+			}
+		}
+
+		if _, errExec := tx.ExecContext(ctx, `UPDATE sync_state SET enriched_seq = enriched_seq + 1, enriched_on = ?`,
+			time.Now().Unix()); errExec != nil {
+			return errors.Join(errExec, errors.New("failed to update enrichment version"))
+		}
+
+		return nil
+	})
+}
+
+// enrichmentVersion returns a value that changes whenever steam profiles are updated, along with the
+// time of the update.
+func enrichmentVersion(ctx context.Context, db querier) (int64, time.Time, error) {
+	var (
+		version    int64
+		enrichedOn int64
+	)
+
+	if errScan := db.QueryRowContext(ctx, `SELECT enriched_seq, enriched_on FROM sync_state`).
+		Scan(&version, &enrichedOn); errScan != nil {
+		return 0, time.Time{}, errors.Join(errScan, errors.New("failed to load enrichment version"))
+	}
+
+	if enrichedOn == 0 {
+		return version, time.Time{}, nil
+	}
+
+	return version, time.Unix(enrichedOn, 0), nil
+}
+
+// getStaleSteamIDs returns the players without a steam profile, or with one last updated before the cutoff,
+// oldest first.
+func getStaleSteamIDs(ctx context.Context, db querier, cutoff time.Time, limit int) ([]steamid.SteamID, error) {
+	const query = `
+		SELECT p.steamid
+		FROM player p
+		LEFT JOIN steam_profile s ON s.steamid = p.steamid
+		WHERE p.deleted_on = 0 AND coalesce(s.updated_on, 0) < ?
+		ORDER BY coalesce(s.updated_on, 0), p.steamid
+		LIMIT ?`
+
+	rows, errQuery := db.QueryContext(ctx, query, cutoff.Unix(), limit)
+	if errQuery != nil {
+		return nil, errors.Join(errQuery, errors.New("failed to load stale steam profiles"))
+	}
+
+	defer func() {
+		if errClose := rows.Close(); errClose != nil {
+			slog.Error("Failed to close rows handle", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	var steamIDs []steamid.SteamID
+
+	for rows.Next() {
+		var sid int64
+		if errScan := rows.Scan(&sid); errScan != nil {
+			return nil, errors.Join(errScan, errors.New("error scanning steam id"))
+		}
+
+		steamIDs = append(steamIDs, steamid.New(sid))
+	}
+
+	if rows.Err() != nil {
+		return nil, errors.Join(rows.Err(), errors.New("error reading steam id rows"))
+	}
+
+	return steamIDs, nil
+}
+
+// steamClient calls the steam web api endpoints used for enrichment.
+type steamClient struct {
+	client  *http.Client
+	baseURL string
+	key     string
+}
+
+func newSteamClient(config Config) steamClient {
+	return steamClient{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		baseURL: strings.TrimSuffix(config.SteamAPIURL, "/"),
+		key:     config.SteamKey,
+	}
+}
+
+func (client steamClient) get(ctx context.Context, path string, steamIDs []steamid.SteamID, value any) error {
+	ids := make([]string, len(steamIDs))
+	for idx, sid := range steamIDs {
+		ids[idx] = sid.String()
+	}
+
+	query := url.Values{"key": {client.key}, "steamids": {strings.Join(ids, ",")}}
+
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, client.baseURL+path+"?"+query.Encode(), nil)
+	if errReq != nil {
+		return errors.Join(errReq, errors.New("failed to setup http request"))
+	}
+
+	resp, errResp := client.client.Do(req)
+	if errResp != nil {
+		return errors.Join(errResp, errors.New("failed to call steam api"))
+	}
+
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			slog.Error("failed to close body", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected steam api response status: %d", resp.StatusCode)
+	}
+
+	if errDecode := json.NewDecoder(resp.Body).Decode(value); errDecode != nil {
+		return errors.Join(errDecode, errors.New("failed to decode steam api response"))
+	}
+
+	return nil
+}
+
+type steamPlayerSummaries struct {
+	Response struct {
+		Players []struct {
+			SteamID                  string `json:"steamid"`
+			PersonaName              string `json:"personaname"`
+			AvatarFull               string `json:"avatarfull"`
+			CommunityVisibilityState int    `json:"communityvisibilitystate"`
+			TimeCreated              int64  `json:"timecreated"`
+		} `json:"players"`
+	} `json:"response"`
+}
+
+type steamPlayerBans struct {
+	Players []struct {
+		SteamID          string `json:"SteamId"`
+		NumberOfVACBans  int    `json:"NumberOfVACBans"`
+		NumberOfGameBans int    `json:"NumberOfGameBans"`
+	} `json:"players"`
+}
+
+func visibilityName(state int) string {
+	switch state {
+	case 3:
+		return "public"
+	case 2:
+		return "friends_only"
+	default:
+		return "private"
+	}
+}
+
+// fetchSteamProfiles fetches the profile summaries and bans of up to steamBatchSize players. Players that
+// steam does not return, eg: deleted accounts, are still included so they are not fetched again until
+// they are out of date.
+func (client steamClient) fetchSteamProfiles(ctx context.Context, steamIDs []steamid.SteamID) (map[int64]SteamProfile, error) {
+	var summaries steamPlayerSummaries
+	if errSummaries := client.get(ctx, "/ISteamUser/GetPlayerSummaries/v2/", steamIDs, &summaries); errSummaries != nil {
+		return nil, errSummaries
+	}
+
+	var bans steamPlayerBans
+	if errBans := client.get(ctx, "/ISteamUser/GetPlayerBans/v1/", steamIDs, &bans); errBans != nil {
+		return nil, errBans
+	}
+
+	now := time.Now().Unix()
+	profiles := make(map[int64]SteamProfile, len(steamIDs))
+
+	for _, sid := range steamIDs {
+		profiles[sid.Int64()] = SteamProfile{Visibility: visibilityName(0), UpdatedOn: now}
+	}
+
+	for _, summary := range summaries.Response.Players {
+		sid := steamid.New(summary.SteamID)
+
+		profile, found := profiles[sid.Int64()]
+		if !found {
+			continue
+		}
+
+		profile.PersonaName = summary.PersonaName
+		profile.Avatar = summary.AvatarFull
+		profile.Visibility = visibilityName(summary.CommunityVisibilityState)
+		profile.TimeCreated = summary.TimeCreated
+		profiles[sid.Int64()] = profile
+	}
+
+	for _, ban := range bans.Players {
+		sid := steamid.New(ban.SteamID)
+
+		profile, found := profiles[sid.Int64()]
+		if !found {
+			continue
+		}
+
+		profile.VACBans = ban.NumberOfVACBans
+		profile.GameBans = ban.NumberOfGameBans
+		profiles[sid.Int64()] = profile
+	}
+
+	return profiles, nil
+}
+
+// EnrichPlayers fetches the steam profiles of all players that do not have one, or have one older than
+// the enrich_interval, returning the amount of profiles updated.
+func EnrichPlayers(ctx context.Context, database *sql.DB, config Config) (int, error) {
+	client := newSteamClient(config)
+	cutoff := time.Now().Add(-config.EnrichInterval)
+	updated := 0
+
+	for {
+		steamIDs, errSteamIDs := getStaleSteamIDs(ctx, database, cutoff, steamBatchSize)
+		if errSteamIDs != nil {
+			return updated, errSteamIDs
+		}
+
+		if len(steamIDs) == 0 {
+			return updated, nil
+		}
+
+		profiles, errFetch := client.fetchSteamProfiles(ctx, steamIDs)
+		if errFetch != nil {
+			return updated, errFetch
+		}
+
+		if errSave := saveSteamProfiles(ctx, database, profiles); errSave != nil {
+			return updated, errSave
+		}
+
+		updated += len(profiles)
+	}
+}
+
+// EnrichWorker periodically updates the steam profiles of listed players. Profiles are refreshed once they
+// are older than the enrich_interval, which disables enrichment when set to 0.
+func EnrichWorker(ctx context.Context, database *sql.DB, config Config) {
+	if config.EnrichInterval <= 0 || config.SteamKey == "" {
+		return
+	}
+
+	ticker := time.NewTicker(enrichCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		updated, errEnrich := EnrichPlayers(ctx, database, config)
+		if errEnrich != nil {
+			slog.Error("Failed to enrich players", slog.String("error", errEnrich.Error()))
+		} else if updated > 0 {
+			slog.Info("Updated steam profiles", slog.Int("count", updated))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package tf2bdd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leighmacdonald/steamid/v4/steamid"
+)
+
+var (
+	ErrNotUpstream    = errors.New("entry was not added from an upstream list")
+	ErrAlreadyClaimed = errors.New("entry is already claimed")
+	errUpstreamEmpty  = errors.New("upstream list has no players")
+	errUpstreamSize   = errors.New("upstream list is too large")
+)
+
+const (
+	// defaultUpstreamInterval is used for upstreams that do not define an interval.
+	defaultUpstreamInterval = time.Hour
+	upstreamTimeout         = time.Minute
+	upstreamMaxSize         = 100 * 1024 * 1024
+)
+
+func (upstream UpstreamConfig) interval() time.Duration {
+	if upstream.Interval <= 0 {
+		return defaultUpstreamInterval
+	}
+
+	return upstream.Interval
+}
+
+// authorLabel is recorded in the audit log for the changes made by syncing the upstream.
+func (upstream UpstreamConfig) authorLabel() string {
+	return upstreamLabel(upstream.Name)
+}
+
+func upstreamLabel(name string) string {
+	return "upstream: " + name
+}
+
+// mapAttributes converts the upstream attributes to local ones, dropping any that are not mapped.
+func (upstream UpstreamConfig) mapAttributes(knownAttributes []string, attributes []string) []string {
+	if len(upstream.Attributes) == 0 {
+		return filterAttributes(knownAttributes, attributes)
+	}
+
+	var mapped []string
+
+	for _, attr := range attributes {
+		if local, found := upstream.Attributes[strings.ToLower(attr)]; found && !slices.Contains(mapped, local) {
+			mapped = append(mapped, local)
+		}
+	}
+
+	return mapped
+}
+
+// UpstreamResult summarizes the changes made by syncing an upstream list.
+type UpstreamResult struct {
+	Added   int
+	Updated int
+	Removed int
+}
+
+// fetchUpstream downloads the upstream list. If the list has not changed since the etag was returned,
+// false is returned along with an empty list.
+func fetchUpstream(ctx context.Context, client *http.Client, upstream UpstreamConfig, etag string) (PlayerListRoot, string, bool, error) {
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	if errReq != nil {
+		return PlayerListRoot{}, "", false, errors.Join(errReq, errors.New("failed to setup http request"))
+	}
+
+	req.Header.Set("User-Agent", "tf2bdd")
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, errResp := client.Do(req)
+	if errResp != nil {
+		return PlayerListRoot{}, "", false, errors.Join(errResp, errors.New("failed to download upstream list"))
+	}
+
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			slog.Error("failed to close body", slog.String("error", errClose.Error()))
+		}
+	}()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return PlayerListRoot{}, etag, false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return PlayerListRoot{}, "", false, fmt.Errorf("unexpected upstream response status: %d", resp.StatusCode)
+	}
+
+	body, errRead := io.ReadAll(io.LimitReader(resp.Body, upstreamMaxSize+1))
+	if errRead != nil {
+		return PlayerListRoot{}, "", false, errors.Join(errRead, errors.New("failed to read upstream list"))
+	}
+
+	if len(body) > upstreamMaxSize {
+		return PlayerListRoot{}, "", false, errUpstreamSize
+	}
+
+	var playerList PlayerListRoot
+	if errDecode := json.Unmarshal(body, &playerList); errDecode != nil {
+		return PlayerListRoot{}, "", false, errors.Join(errDecode, errors.New("failed to decode upstream list"))
+	}
+
+	return playerList, resp.Header.Get("ETag"), true, nil
+}
+
+// mergeUpstream merges the upstream list into the local one. New players are added with the upstream as
+// their source, and players previously added from the upstream are updated, or removed if they are no
+// longer listed. Entries that were added locally, by another upstream, claimed by a moderator or deleted
+// locally are left untouched.
+func mergeUpstream(ctx context.Context, database *sql.DB, config Config, upstream UpstreamConfig, playerList PlayerListRoot) (UpstreamResult, error) {
+	var result UpstreamResult
+
+	// An empty list is far more likely to be a broken upstream than every entry having been removed.
+	if len(playerList.Players) == 0 {
+		return result, errUpstreamEmpty
+	}
+
+	ctx = withAuthorLabel(ctx, upstream.authorLabel())
+
+	errTx := withTx(ctx, database, func(tx *sql.Tx) error {
+		result = UpstreamResult{}
+
+		existing, errExisting := queryPlayers(ctx, tx, `SELECT `+playerColumns+` FROM player`)
+		if errExisting != nil {
+			return errExisting
+		}
+
+		players := make(map[int64]Player, len(existing))
+		for _, player := range existing {
+			players[player.SteamID.Int64()] = player
+		}
+
+		listed := map[int64]bool{}
+
+		for _, upstreamPlayer := range playerList.Players {
+			if !upstreamPlayer.SteamID.Valid() || listed[upstreamPlayer.SteamID.Int64()] {
+				continue
+			}
+
+			attributes := upstream.mapAttributes(config.KnownAttributes, upstreamPlayer.Attributes)
+			if len(attributes) == 0 {
+				continue
+			}
+
+			listed[upstreamPlayer.SteamID.Int64()] = true
+
+			current, found := players[upstreamPlayer.SteamID.Int64()]
+
+			restored := found && removedByUpstream(current, upstream)
+			if restored {
+				// Entries removed by an earlier sync are brought back once the upstream lists them again.
+				if errRestore := restoreDeletedPlayer(ctx, tx, current.SteamID, 0); errRestore != nil {
+					return errRestore
+				}
+
+				current.DeletedOn = time.Time{}
+				current.DeletedBy = 0
+				result.Added++
+			}
+
+			if found && current.DeletedOn.IsZero() {
+				// Names are recorded for every listed player, even those the upstream cannot change.
+				if errName := observeName(ctx, tx, current.SteamID, upstreamPlayer.LastSeen.PlayerName,
+					upstream.authorLabel(), upstreamPlayer.LastSeen.Time); errName != nil {
+					return errName
+				}
+			}
+
+			if !found {
+				player := Player{
+					SteamID:    upstreamPlayer.SteamID,
+					Attributes: attributes,
+					LastSeen:   upstreamPlayer.LastSeen,
+					Proof:      upstreamPlayer.Proof,
+					Confirmed:  upstream.Trust != TrustConfirm,
+					Source:     upstream.Name,
+				}
+
+				if errAdd := addPlayer(ctx, tx, player, 0, auditImport); errAdd != nil {
+					return errAdd
+				}
+
+				result.Added++
+
+				continue
+			}
+
+			if !managedByUpstream(current, upstream) {
+				continue
+			}
+
+			updated := current
+			updated.Attributes = attributes
+
+			if upstreamPlayer.LastSeen.PlayerName != "" {
+				updated.LastSeen = upstreamPlayer.LastSeen
+			}
+
+			if sameAttributes(updated.Attributes, current.Attributes) && updated.LastSeen == current.LastSeen {
+				continue
+			}
+
+			// Proof is kept as is, so that local proof notes and ordering survive each sync.
+			if errSave := savePlayer(ctx, tx, updated, 0); errSave != nil {
+				return errSave
+			}
+
+			if !restored {
+				result.Updated++
+			}
+		}
+
+		for _, player := range existing {
+			if !managedByUpstream(player, upstream) || listed[player.SteamID.Int64()] {
+				continue
+			}
+
+			if errDrop := dropPlayer(ctx, tx, player.SteamID, 0); errDrop != nil {
+				return errDrop
+			}
+
+			result.Removed++
+		}
+
+		return nil
+	})
+
+	return result, errTx
+}
+
+// managedByUpstream returns true if the entry was added by the upstream and has not since been claimed
+// or deleted locally.
+func managedByUpstream(player Player, upstream UpstreamConfig) bool {
+	return player.Source == upstream.Name && player.ClaimedBy == 0 && player.DeletedOn.IsZero()
+}
+
+// removedByUpstream returns true if the entry was deleted by a sync of the upstream, rather than by a
+// local user.
+func removedByUpstream(player Player, upstream UpstreamConfig) bool {
+	return player.Source == upstream.Name && player.ClaimedBy == 0 && !player.DeletedOn.IsZero() && player.DeletedBy == 0
+}
+
+// sameAttributes returns true if both contain the same attributes, regardless of their order.
+func sameAttributes(a []string, b []string) bool {
+	sortedA := slices.Clone(a)
+	sortedB := slices.Clone(b)
+
+	slices.Sort(sortedA)
+	slices.Sort(sortedB)
+
+	return slices.Equal(sortedA, sortedB)
+}
+
+// SyncUpstreams fetches and merges every configured upstream list once, returning the result of each
+// upstream that was synced successfully.
+func SyncUpstreams(ctx context.Context, database *sql.DB, config Config) (map[string]UpstreamResult, error) {
+	client := &http.Client{Timeout: upstreamTimeout}
+	results := map[string]UpstreamResult{}
+
+	var errs error
+
+	for _, upstream := range config.Upstreams {
+		result, _, errSync := syncUpstream(ctx, client, database, config, upstream, "")
+		if errSync != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", upstream.Name, errSync))
+
+			continue
+		}
+
+		results[upstream.Name] = result
+	}
+
+	return results, errs
+}
+
+func syncUpstream(ctx context.Context, client *http.Client, database *sql.DB, config Config, upstream UpstreamConfig,
+	etag string,
+) (UpstreamResult, string, error) {
+	playerList, newETag, modified, errFetch := fetchUpstream(ctx, client, upstream, etag)
+	if errFetch != nil {
+		return UpstreamResult{}, etag, errFetch
+	}
+
+	if !modified {
+		return UpstreamResult{}, etag, nil
+	}
+
+	result, errMerge := mergeUpstream(ctx, database, config, upstream, playerList)
+	if errMerge != nil {
+		// The list must be fetched again, so the merge is retried on the next sync.
+		return result, "", errMerge
+	}
+
+	slog.Info("Synced upstream list", slog.String("upstream", upstream.Name), slog.Int("added", result.Added),
+		slog.Int("updated", result.Updated), slog.Int("removed", result.Removed))
+
+	return result, newETag, nil
+}
+
+// UpstreamWorker periodically syncs each upstream list on its own interval until the context is cancelled.
+func UpstreamWorker(ctx context.Context, database *sql.DB, config Config) {
+	client := &http.Client{Timeout: upstreamTimeout}
+
+	var waitGroup sync.WaitGroup
+
+	for _, upstream := range config.Upstreams {
+		waitGroup.Add(1)
+
+		go func(upstream UpstreamConfig) {
+			defer waitGroup.Done()
+
+			ticker := time.NewTicker(upstream.interval())
+			defer ticker.Stop()
+
+			etag := ""
+
+			for {
+				var errSync error
+				if _, etag, errSync = syncUpstream(ctx, client, database, config, upstream, etag); errSync != nil {
+					slog.Error("Failed to sync upstream list", slog.String("upstream", upstream.Name),
+						slog.String("error", errSync.Error()))
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(upstream)
+	}
+
+	waitGroup.Wait()
+}
+
+// claimPlayer takes over an entry added from an upstream list, so that it is no longer updated or
+// removed by the upstream.
+func claimPlayer(ctx context.Context, database *sql.DB, steamID steamid.SteamID, author int64) error {
+	return withTx(ctx, database, func(tx *sql.Tx) error {
+		before, errBefore := getPlayer(ctx, tx, steamID)
+		if errBefore != nil {
+			return errBefore
+		}
+
+		if before.Source == "" {
+			return ErrNotUpstream
+		}
+
+		if before.ClaimedBy != 0 {
+			return ErrAlreadyClaimed
+		}
+
+		if _, errExec := tx.ExecContext(ctx, `UPDATE player SET claimed_by = ? WHERE steamid = ?`, author, steamID.Int64()); errExec != nil {
+			return errors.Join(errExec, errors.New("failed to claim player"))
+		}
+
+		after := before
+		after.ClaimedBy = author
+
+		return addAuditEntry(ctx, tx, auditClaim, steamID, author, &before, &after)
+	})
+}
+
+func claimEntry(ctx context.Context, database *sql.DB, sid steamid.SteamID, author int64) (string, error) {
+	if errClaim := claimPlayer(ctx, database, sid, author); errClaim != nil {
+		switch {
+		case errors.Is(errClaim, ErrNotFound):
+			return "", fmt.Errorf("steam id does not exist in database: %s", sid.String())
+		case errors.Is(errClaim, ErrNotUpstream):
+			return "", fmt.Errorf("steam id was not added from an upstream list: %s", sid.String())
+		case errors.Is(errClaim, ErrAlreadyClaimed):
+			return "", fmt.Errorf("steam id is already claimed: %s", sid.String())
+		}
+
+		return "", errClaim
+	}
+
+	return fmt.Sprintf("Claimed entry, it will no longer be changed by its upstream list: %s", sid.String()), nil
+}